@@ -0,0 +1,93 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Severity is the severity of the incidents of a release
+// +kubebuilder:validation:Enum=low;medium;high;critical
+type Severity string
+
+const (
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+// RestartThreshold is the number of restarts of the containers of a pod within a window of time
+// after which an incident is opened for the pod
+type RestartThreshold struct {
+	// Restarts is the minimum number of restarts within the window
+	// +kubebuilder:validation:Minimum=1
+	Restarts int32 `json:"restarts"`
+
+	// Window is the period of time over which the restarts are counted, such as "10m"
+	Window metav1.Duration `json:"window"`
+}
+
+// IncidentPolicySpec defines how the agent handles the incidents of the releases in the
+// namespace of the policy
+type IncidentPolicySpec struct {
+	// Releases are the names of the releases which the policy applies to. The policy applies to
+	// all releases of the namespace if it is empty.
+	// +optional
+	Releases []string `json:"releases,omitempty"`
+
+	// Suppress stops incidents from being opened and notified for the releases
+	// +optional
+	Suppress bool `json:"suppress,omitempty"`
+
+	// RestartThreshold delays the incidents of crashing pods until their containers have
+	// restarted often enough
+	// +optional
+	RestartThreshold *RestartThreshold `json:"restartThreshold,omitempty"`
+
+	// Severity is the severity which the incidents of the releases are stored and notified with
+	// +optional
+	Severity Severity `json:"severity,omitempty"`
+}
+
+// IncidentPolicyStatus defines the observed state of IncidentPolicy
+type IncidentPolicyStatus struct {
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// IncidentPolicy is the Schema for the incidentpolicies API
+type IncidentPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IncidentPolicySpec   `json:"spec,omitempty"`
+	Status IncidentPolicyStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// IncidentPolicyList contains a list of IncidentPolicy
+type IncidentPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IncidentPolicy `json:"items"`
+}
+
+// AppliesTo returns true if the policy applies to the release
+func (p *IncidentPolicy) AppliesTo(releaseName string) bool {
+	if len(p.Spec.Releases) == 0 {
+		return true
+	}
+
+	for _, name := range p.Spec.Releases {
+		if name == releaseName {
+			return true
+		}
+	}
+
+	return false
+}
+
+func init() {
+	SchemeBuilder.Register(&IncidentPolicy{}, &IncidentPolicyList{})
+}
@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/porter-dev/porter-agent/pkg/store"
+	"github.com/porter-dev/porter-agent/pkg/utils"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/viper"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var (
+	cronJobMissedScheduleTolerance time.Duration
+	cronJobSuspendedThreshold      time.Duration
+	cronJobFailureThreshold        int
+)
+
+func init() {
+	viper.SetDefault("CRONJOB_MISSED_SCHEDULE_TOLERANCE", "5m")
+	viper.SetDefault("CRONJOB_SUSPENDED_THRESHOLD", "24h")
+	viper.SetDefault("CRONJOB_FAILURE_THRESHOLD", 1)
+	viper.AutomaticEnv()
+
+	cronJobMissedScheduleTolerance = viper.GetDuration("CRONJOB_MISSED_SCHEDULE_TOLERANCE")
+	cronJobSuspendedThreshold = viper.GetDuration("CRONJOB_SUSPENDED_THRESHOLD")
+	cronJobFailureThreshold = viper.GetInt("CRONJOB_FAILURE_THRESHOLD")
+}
+
+// CronJobReconciler reconciles a CronJob object
+type CronJobReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	Store           store.IncidentStore
+	KubeClient      *kubernetes.Clientset
+	PodFilter       utils.PodFilter
+	ReleaseResolver *utils.ReleaseResolver
+
+	logger logr.Logger
+}
+
+//+kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch
+
+// Reconcile opens an incident for the release of a cron job when its latest runs have failed one
+// after the other, or when it has fallen behind its schedule, and resolves it once the cron job
+// is back on track.
+func (r *CronJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = log.FromContext(ctx)
+
+	instance := &batchv1.CronJob{}
+	err := r.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	porterReleaseName := r.ReleaseResolver.GetReleaseName(ctx, instance)
+
+	// we ignore cron jobs which have an empty release name, same as pods
+	if porterReleaseName == "" {
+		return ctrl.Result{}, nil
+	}
+
+	member := objectMember(string(models.CronJobResource), instance.Name)
+
+	event := &models.PodEvent{
+		ChartName:       instance.Labels["helm.sh/chart"],
+		PodName:         member,
+		Namespace:       instance.Namespace,
+		OwnerName:       porterReleaseName,
+		OwnerType:       string(models.CronJobResource),
+		Timestamp:       time.Now().Unix(),
+		Severity:        models.SeverityMedium,
+		ContainerEvents: make(map[string]*models.ContainerEvent),
+	}
+
+	agentCreationTimestamp, err := r.Store.GetAgentCreationTimestamp(ctx)
+	if err != nil {
+		r.logger.Error(err, "incidentStore.GetAgentCreationTimestamp ERROR")
+		return ctrl.Result{}, err
+	}
+
+	failedJob, failures, err := r.getConsecutiveFailures(ctx, instance)
+	if err != nil {
+		r.logger.Error(err, "error fetching jobs of cron job", "cronjob", instance.Name)
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	if failures >= cronJobFailureThreshold && failedJob != nil {
+		if failedJob.GetCreationTimestamp().Unix() >= agentCreationTimestamp {
+			if failures == 1 {
+				event.Reason = "The latest run of the cron job failed"
+			} else {
+				event.Reason = fmt.Sprintf("%d consecutive runs of the cron job failed", failures)
+			}
+
+			event.Message = fmt.Sprintf("The latest %d runs of the cron job %s failed. The latest failed run was job %s.",
+				failures, instance.Name, failedJob.Name)
+
+			if failed := getJobCondition(failedJob, batchv1.JobFailed); failed != nil && failed.Message != "" {
+				event.Message += fmt.Sprintf(" %s.", failed.Message)
+			}
+
+			err = reportJobFailure(ctx, r.Client, r.KubeClient, r.Store, r.PodFilter, failedJob, porterReleaseName, event)
+			if err != nil {
+				r.logger.Error(err, "error reporting failed cron job", "cronjob", instance.Name)
+				return ctrl.Result{Requeue: true}, err
+			}
+
+			return ctrl.Result{}, nil
+		}
+	}
+
+	summary, details, requeueAfter := getCronJobScheduleProblem(instance, time.Unix(agentCreationTimestamp, 0), time.Now())
+
+	if summary == "" {
+		err = resolveActiveIncidentMember(ctx, r.Store, porterReleaseName, instance.Namespace, member)
+		if err != nil {
+			r.logger.Error(err, "error resolving cron job incident", "cronjob", instance.Name)
+			return ctrl.Result{Requeue: true}, err
+		}
+
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	event.Reason = summary
+	event.Message = details
+
+	// a suspended cron job is most likely suspended on purpose
+	if instance.Spec.Suspend != nil && *instance.Spec.Suspend {
+		event.Severity = models.SeverityLow
+	}
+
+	_, err = addEventToActiveIncident(ctx, r.Client, r.Store, porterReleaseName, instance.Namespace, event)
+	if err != nil {
+		r.logger.Error(err, "error adding event to cron job incident", "cronjob", instance.Name)
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// getConsecutiveFailures returns the number of finished runs of the cron job which failed one after
+// the other, counting back from the latest finished run, along with the latest failed job.
+func (r *CronJobReconciler) getConsecutiveFailures(ctx context.Context, cronJob *batchv1.CronJob) (*batchv1.Job, int, error) {
+	jobs := &batchv1.JobList{}
+
+	err := r.List(ctx, jobs, client.InNamespace(cronJob.Namespace))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var runs []*batchv1.Job
+
+	for i := range jobs.Items {
+		if owner := metav1.GetControllerOf(&jobs.Items[i]); owner != nil && owner.UID == cronJob.UID {
+			runs = append(runs, &jobs.Items[i])
+		}
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].CreationTimestamp.After(runs[j].CreationTimestamp.Time)
+	})
+
+	var latestFailed *batchv1.Job
+	failures := 0
+
+	for _, job := range runs {
+		if getJobCondition(job, batchv1.JobComplete) != nil {
+			break
+		} else if getJobCondition(job, batchv1.JobFailed) != nil {
+			if latestFailed == nil {
+				latestFailed = job
+			}
+
+			failures++
+		}
+
+		// runs which are still active do not break the streak of failures
+	}
+
+	return latestFailed, failures, nil
+}
+
+// getCronJobScheduleProblem returns the summary and details if the cron job has missed its schedule
+// or has been suspended for too long, along with the time after which it should be checked again.
+// Like pods created before the agent, problems which began before the agent was created are not
+// reported, so the schedule is only checked from the creation of the agent on.
+func getCronJobScheduleProblem(cronJob *batchv1.CronJob, agentCreatedAt, now time.Time) (string, string, time.Duration) {
+	lastScheduled, checkedSince := getCronJobScheduleCheckedSince(cronJob, agentCreatedAt)
+
+	if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+		if remaining := checkedSince.Add(cronJobSuspendedThreshold).Sub(now); remaining > 0 {
+			return "", "", remaining
+		}
+
+		return "The cron job has been suspended",
+			fmt.Sprintf("The cron job %s is suspended and has not run since %s. Resume the cron job if it "+
+				"should be running on its schedule.", cronJob.Name, lastScheduled.UTC().Format(time.RFC1123)), 0
+	}
+
+	expected, deadline, err := getCronJobExpectedRun(cronJob, agentCreatedAt)
+	if err != nil {
+		return "The schedule of the cron job is invalid",
+			fmt.Sprintf("The schedule %q of the cron job %s could not be parsed: %s", cronJob.Spec.Schedule,
+				cronJob.Name, err.Error()), 0
+	}
+
+	if remaining := deadline.Sub(now); remaining > 0 {
+		return "", "", remaining
+	}
+
+	return "The cron job has missed its schedule",
+		fmt.Sprintf("The cron job %s was scheduled to run at %s, but it has not run since %s. Make sure that "+
+			"the cron job is not blocked by a previous run which is still active.", cronJob.Name,
+			expected.UTC().Format(time.RFC1123), lastScheduled.UTC().Format(time.RFC1123)), 0
+}
+
+// getCronJobExpectedRun returns the next run of the cron job which is expected after the time its
+// schedule is checked since, and the deadline after which that run counts as missed. The deadline
+// is the starting deadline of the cron job if it has one.
+func getCronJobExpectedRun(cronJob *batchv1.CronJob, agentCreatedAt time.Time) (time.Time, time.Time, error) {
+	schedule := cronJob.Spec.Schedule
+
+	if cronJob.Spec.TimeZone != nil {
+		schedule = fmt.Sprintf("CRON_TZ=%s %s", *cronJob.Spec.TimeZone, schedule)
+	}
+
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	tolerance := cronJobMissedScheduleTolerance
+
+	if cronJob.Spec.StartingDeadlineSeconds != nil {
+		tolerance = time.Duration(*cronJob.Spec.StartingDeadlineSeconds) * time.Second
+	}
+
+	_, checkedSince := getCronJobScheduleCheckedSince(cronJob, agentCreatedAt)
+	expected := sched.Next(checkedSince)
+
+	return expected, expected.Add(tolerance), nil
+}
+
+// getCronJobScheduleCheckedSince returns the time the cron job was last scheduled at, or created at
+// if it has never been scheduled, and the time its schedule is checked since, which is never before
+// the creation of the agent
+func getCronJobScheduleCheckedSince(cronJob *batchv1.CronJob, agentCreatedAt time.Time) (time.Time, time.Time) {
+	lastScheduled := cronJob.CreationTimestamp.Time
+
+	if cronJob.Status.LastScheduleTime != nil {
+		lastScheduled = cronJob.Status.LastScheduleTime.Time
+	}
+
+	if agentCreatedAt.After(lastScheduled) {
+		return lastScheduled, agentCreatedAt
+	}
+
+	return lastScheduled, lastScheduled
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CronJobReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&batchv1.CronJob{}).
+		Owns(&batchv1.Job{}).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, enqueueNamespaceObjects(mgr.GetClient(), &batchv1.CronJobList{}),
+			builder.WithPredicates(namespaceSelectedPredicate())).
+		WithEventFilter(namespacePredicate(mgr.GetClient())).
+		Complete(r)
+}
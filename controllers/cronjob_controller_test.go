@@ -0,0 +1,279 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestCronJob(schedule string, created time.Time, lastScheduled *time.Time) *batchv1.CronJob {
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "report",
+			Namespace:         "default",
+			UID:               types.UID("cronjob-uid"),
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule: schedule,
+		},
+	}
+
+	if lastScheduled != nil {
+		t := metav1.NewTime(*lastScheduled)
+		cronJob.Status.LastScheduleTime = &t
+	}
+
+	return cronJob
+}
+
+func mustParseTime(t *testing.T, value string) time.Time {
+	t.Helper()
+
+	res, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("error parsing time %s: %v", value, err)
+	}
+
+	return res
+}
+
+func TestGetCronJobExpectedRun(t *testing.T) {
+	created := mustParseTime(t, "2023-03-01T00:00:00Z")
+	lastScheduled := mustParseTime(t, "2023-03-10T14:00:00Z")
+	startingDeadlineSeconds := int64(600)
+	timeZone := "America/New_York"
+
+	tests := []struct {
+		name             string
+		cronJob          *batchv1.CronJob
+		agentCreatedAt   time.Time
+		expectedRun      string
+		expectedDeadline string
+	}{
+		{
+			name:             "from last schedule",
+			cronJob:          newTestCronJob("0 * * * *", created, &lastScheduled),
+			agentCreatedAt:   created,
+			expectedRun:      "2023-03-10T15:00:00Z",
+			expectedDeadline: "2023-03-10T15:05:00Z",
+		},
+		{
+			name:             "never scheduled",
+			cronJob:          newTestCronJob("30 * * * *", created, nil),
+			agentCreatedAt:   created.Add(-time.Hour),
+			expectedRun:      "2023-03-01T00:30:00Z",
+			expectedDeadline: "2023-03-01T00:35:00Z",
+		},
+		{
+			name:             "agent created after last schedule",
+			cronJob:          newTestCronJob("0 * * * *", created, &lastScheduled),
+			agentCreatedAt:   mustParseTime(t, "2023-03-12T08:20:00Z"),
+			expectedRun:      "2023-03-12T09:00:00Z",
+			expectedDeadline: "2023-03-12T09:05:00Z",
+		},
+		{
+			name: "starting deadline",
+			cronJob: func() *batchv1.CronJob {
+				cronJob := newTestCronJob("0 * * * *", created, &lastScheduled)
+				cronJob.Spec.StartingDeadlineSeconds = &startingDeadlineSeconds
+				return cronJob
+			}(),
+			agentCreatedAt:   created,
+			expectedRun:      "2023-03-10T15:00:00Z",
+			expectedDeadline: "2023-03-10T15:10:00Z",
+		},
+		{
+			// daylight saving time starts in New York on 2023-03-12, so 9:00 moves from 14:00 to 13:00 UTC
+			name: "time zone",
+			cronJob: func() *batchv1.CronJob {
+				cronJob := newTestCronJob("0 9 * * *", created, &lastScheduled)
+				cronJob.Spec.TimeZone = &timeZone
+				return cronJob
+			}(),
+			agentCreatedAt:   mustParseTime(t, "2023-03-11T15:00:00Z"),
+			expectedRun:      "2023-03-12T13:00:00Z",
+			expectedDeadline: "2023-03-12T13:05:00Z",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			run, deadline, err := getCronJobExpectedRun(test.cronJob, test.agentCreatedAt)
+			if err != nil {
+				t.Fatalf("error computing expected run: %v", err)
+			}
+
+			if expected := mustParseTime(t, test.expectedRun); !run.Equal(expected) {
+				t.Errorf("expected run at %s, got %s", expected, run.UTC())
+			}
+
+			if expected := mustParseTime(t, test.expectedDeadline); !deadline.Equal(expected) {
+				t.Errorf("expected deadline at %s, got %s", expected, deadline.UTC())
+			}
+		})
+	}
+}
+
+func TestGetCronJobScheduleProblem(t *testing.T) {
+	created := mustParseTime(t, "2023-03-01T00:00:00Z")
+	lastScheduled := mustParseTime(t, "2023-03-10T14:00:00Z")
+	suspend := true
+	timeZone := "Mars/Olympus_Mons"
+
+	tests := []struct {
+		name                 string
+		cronJob              *batchv1.CronJob
+		agentCreatedAt       time.Time
+		now                  string
+		expectedSummary      string
+		expectedRequeueAfter time.Duration
+	}{
+		{
+			name:                 "before deadline",
+			cronJob:              newTestCronJob("0 * * * *", created, &lastScheduled),
+			agentCreatedAt:       created,
+			now:                  "2023-03-10T15:04:00Z",
+			expectedRequeueAfter: time.Minute,
+		},
+		{
+			name:            "missed schedule",
+			cronJob:         newTestCronJob("0 * * * *", created, &lastScheduled),
+			agentCreatedAt:  created,
+			now:             "2023-03-10T15:06:00Z",
+			expectedSummary: "The cron job has missed its schedule",
+		},
+		{
+			name:                 "missed schedule before agent was created",
+			cronJob:              newTestCronJob("0 * * * *", created, &lastScheduled),
+			agentCreatedAt:       mustParseTime(t, "2023-03-12T08:20:00Z"),
+			now:                  "2023-03-12T08:21:00Z",
+			expectedRequeueAfter: 44 * time.Minute,
+		},
+		{
+			name: "suspended",
+			cronJob: func() *batchv1.CronJob {
+				cronJob := newTestCronJob("0 * * * *", created, &lastScheduled)
+				cronJob.Spec.Suspend = &suspend
+				return cronJob
+			}(),
+			agentCreatedAt:  created,
+			now:             "2023-03-11T14:00:01Z",
+			expectedSummary: "The cron job has been suspended",
+		},
+		{
+			name: "suspended recently",
+			cronJob: func() *batchv1.CronJob {
+				cronJob := newTestCronJob("0 * * * *", created, &lastScheduled)
+				cronJob.Spec.Suspend = &suspend
+				return cronJob
+			}(),
+			agentCreatedAt:       mustParseTime(t, "2023-03-11T12:00:00Z"),
+			now:                  "2023-03-11T14:00:00Z",
+			expectedRequeueAfter: cronJobSuspendedThreshold - 2*time.Hour,
+		},
+		{
+			name:            "invalid schedule",
+			cronJob:         newTestCronJob("every hour", created, &lastScheduled),
+			agentCreatedAt:  created,
+			now:             "2023-03-10T14:30:00Z",
+			expectedSummary: "The schedule of the cron job is invalid",
+		},
+		{
+			name: "invalid time zone",
+			cronJob: func() *batchv1.CronJob {
+				cronJob := newTestCronJob("0 * * * *", created, &lastScheduled)
+				cronJob.Spec.TimeZone = &timeZone
+				return cronJob
+			}(),
+			agentCreatedAt:  created,
+			now:             "2023-03-10T14:30:00Z",
+			expectedSummary: "The schedule of the cron job is invalid",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			summary, details, requeueAfter := getCronJobScheduleProblem(test.cronJob, test.agentCreatedAt, mustParseTime(t, test.now))
+
+			if summary != test.expectedSummary {
+				t.Errorf("expected summary %q, got %q", test.expectedSummary, summary)
+			}
+
+			if (summary == "") != (details == "") {
+				t.Errorf("expected details only with a summary, got %q", details)
+			}
+
+			if requeueAfter != test.expectedRequeueAfter {
+				t.Errorf("expected requeue after %s, got %s", test.expectedRequeueAfter, requeueAfter)
+			}
+		})
+	}
+}
+
+func TestGetConsecutiveFailures(t *testing.T) {
+	created := mustParseTime(t, "2023-03-01T00:00:00Z")
+	cronJob := newTestCronJob("0 * * * *", created, nil)
+
+	newJob := func(name string, hour int, condition batchv1.JobConditionType, owned bool) *batchv1.Job {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         cronJob.Namespace,
+				CreationTimestamp: metav1.NewTime(created.Add(time.Duration(hour) * time.Hour)),
+			},
+		}
+
+		if owned {
+			controller := true
+
+			job.OwnerReferences = []metav1.OwnerReference{
+				{
+					APIVersion: "batch/v1",
+					Kind:       "CronJob",
+					Name:       cronJob.Name,
+					UID:        cronJob.UID,
+					Controller: &controller,
+				},
+			}
+		}
+
+		if condition != "" {
+			job.Status.Conditions = []batchv1.JobCondition{{Type: condition, Status: corev1.ConditionTrue}}
+		}
+
+		return job
+	}
+
+	kubeClient := fake.NewClientBuilder().WithObjects(
+		newJob("report-1", 1, batchv1.JobComplete, true),
+		newJob("report-2", 2, batchv1.JobFailed, true),
+		newJob("report-3", 3, batchv1.JobFailed, true),
+		// a run of another cron job does not count
+		newJob("other-4", 4, batchv1.JobComplete, false),
+		newJob("report-5", 5, batchv1.JobFailed, true),
+		// the active run does not break the streak of failures
+		newJob("report-6", 6, "", true),
+	).Build()
+
+	r := &CronJobReconciler{Client: kubeClient}
+
+	failedJob, failures, err := r.getConsecutiveFailures(context.Background(), cronJob)
+	if err != nil {
+		t.Fatalf("error counting consecutive failures: %v", err)
+	}
+
+	if failures != 3 {
+		t.Errorf("expected 3 consecutive failures, got %d", failures)
+	}
+
+	if failedJob == nil || failedJob.Name != "report-5" {
+		t.Errorf("expected report-5 to be the latest failed job, got %v", failedJob)
+	}
+}
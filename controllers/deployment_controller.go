@@ -0,0 +1,308 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/porter-dev/porter-agent/pkg/store"
+	"github.com/porter-dev/porter-agent/pkg/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	revisionAnnotation = "deployment.kubernetes.io/revision"
+
+	// the number of failing pods of a rollout whose events are added to the incident
+	maxRolloutPods = 3
+)
+
+// DeploymentReconciler reconciles a Deployment object
+type DeploymentReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	Store           store.IncidentStore
+	KubeClient      *kubernetes.Clientset
+	ReleaseResolver *utils.ReleaseResolver
+
+	logger logr.Logger
+}
+
+//+kubebuilder:rbac:groups=apps,resources=deployments;replicasets,verbs=get;list;watch
+
+// Reconcile opens a rollout incident for the release of a deployment whose new replica set did not
+// become ready within its progress deadline, and resolves it once the deployment progresses again.
+// This catches stuck rollouts even when enough pods of the old replica set are ready for the pod
+// failures to be ignored.
+func (r *DeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = log.FromContext(ctx)
+
+	instance := &appsv1.Deployment{}
+	err := r.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	porterReleaseName := r.ReleaseResolver.GetReleaseName(ctx, instance)
+
+	// we ignore deployments which have an empty release name, same as pods
+	if porterReleaseName == "" {
+		return ctrl.Result{}, nil
+	}
+
+	member := objectMember(string(models.DeploymentResource), instance.Name)
+
+	var progressing *appsv1.DeploymentCondition
+
+	for i := range instance.Status.Conditions {
+		if instance.Status.Conditions[i].Type == appsv1.DeploymentProgressing {
+			progressing = &instance.Status.Conditions[i]
+		}
+	}
+
+	if progressing == nil || progressing.Reason != "ProgressDeadlineExceeded" {
+		err = resolveActiveIncidentMember(ctx, r.Store, porterReleaseName, instance.Namespace, member)
+		if err != nil {
+			r.logger.Error(err, "error resolving rollout incident", "deployment", instance.Name)
+			return ctrl.Result{Requeue: true}, err
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	agentCreationTimestamp, err := r.Store.GetAgentCreationTimestamp(ctx)
+	if err != nil {
+		r.logger.Error(err, "incidentStore.GetAgentCreationTimestamp ERROR")
+		return ctrl.Result{}, err
+	}
+
+	// like pods created before the agent, rollouts which got stuck before the agent was created are
+	// not reported
+	if progressing.LastTransitionTime.Unix() < agentCreationTimestamp {
+		return ctrl.Result{}, nil
+	}
+
+	rollout := &models.RolloutEvent{}
+
+	newRS, err := r.getNewReplicaSet(ctx, instance)
+	if err != nil {
+		r.logger.Error(err, "error fetching new replica set", "deployment", instance.Name)
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	event := &models.PodEvent{
+		ChartName: instance.Labels["helm.sh/chart"],
+		PodName:   member,
+		Namespace: instance.Namespace,
+		OwnerName: porterReleaseName,
+		OwnerType: string(models.DeploymentResource),
+		Timestamp: time.Now().Unix(),
+		Reason:    "The latest rollout of the application is stuck",
+		Severity:  models.SeverityHigh,
+		Rollout:   rollout,
+	}
+
+	event.Message = fmt.Sprintf("The deployment %s did not finish rolling out within %d seconds: %s",
+		instance.Name, getProgressDeadlineSeconds(instance), progressing.Message)
+
+	if newRS != nil {
+		rollout.ReplicaSet = newRS.Name
+		rollout.Revision = newRS.Annotations[revisionAnnotation]
+
+		event.Message += fmt.Sprintf(" Revision %s of the application has %d of %d replicas ready.",
+			rollout.Revision, newRS.Status.ReadyReplicas, getReplicas(newRS.Spec.Replicas))
+
+		podDetails, err := r.getFailingPodDetails(ctx, newRS, rollout)
+		if err != nil {
+			r.logger.Error(err, "error fetching failing pods of new replica set", "replicaset", newRS.Name)
+			return ctrl.Result{Requeue: true}, err
+		}
+
+		event.Message += podDetails
+	}
+
+	added, err := addEventToActiveIncident(ctx, r.Client, r.Store, porterReleaseName, instance.Namespace, event)
+	if err != nil {
+		r.logger.Error(err, "error adding event to rollout incident", "deployment", instance.Name)
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	if added {
+		r.logger.Info("added rollout event to incident", "deployment", instance.Name, "revision", rollout.Revision)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// getNewReplicaSet returns the replica set of the deployment with the same revision as the deployment
+func (r *DeploymentReconciler) getNewReplicaSet(ctx context.Context, depl *appsv1.Deployment) (*appsv1.ReplicaSet, error) {
+	replicaSets := &appsv1.ReplicaSetList{}
+
+	err := r.List(ctx, replicaSets, client.InNamespace(depl.Namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range replicaSets.Items {
+		rs := &replicaSets.Items[i]
+
+		if owner := metav1.GetControllerOf(rs); owner != nil && owner.UID == depl.UID &&
+			rs.Annotations[revisionAnnotation] == depl.Annotations[revisionAnnotation] {
+			return rs, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// getFailingPodDetails returns the latest warning events of the pods of the replica set which are
+// not ready, and records the names of these pods in the rollout
+func (r *DeploymentReconciler) getFailingPodDetails(
+	ctx context.Context, rs *appsv1.ReplicaSet, rollout *models.RolloutEvent,
+) (string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(rs.Spec.Selector)
+	if err != nil {
+		return "", err
+	}
+
+	pods := &corev1.PodList{}
+
+	err = r.List(ctx, pods, client.InNamespace(rs.Namespace), client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return "", err
+	}
+
+	sort.SliceStable(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+
+	details := ""
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+
+		if owner := metav1.GetControllerOf(pod); owner == nil || owner.UID != rs.UID || isPodReady(pod) {
+			continue
+		}
+
+		rollout.FailingPods = append(rollout.FailingPods, pod.Name)
+
+		if len(rollout.FailingPods) > maxRolloutPods {
+			continue
+		}
+
+		events, err := r.KubeClient.CoreV1().Events(pod.Namespace).List(
+			ctx, metav1.ListOptions{
+				FieldSelector: fmt.Sprintf("involvedObject.kind=Pod,involvedObject.name=%s,type=%s",
+					pod.Name, corev1.EventTypeWarning),
+			},
+		)
+
+		if err != nil || len(events.Items) == 0 {
+			details += fmt.Sprintf(" Pod %s is not ready.", pod.Name)
+			continue
+		}
+
+		sort.SliceStable(events.Items, func(i, j int) bool {
+			return events.Items[i].LastTimestamp.After(events.Items[j].LastTimestamp.Time)
+		})
+
+		details += fmt.Sprintf(" Pod %s is not ready: %s: %s.", pod.Name, events.Items[0].Reason,
+			strings.TrimSuffix(events.Items[0].Message, "."))
+	}
+
+	return details, nil
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+func getProgressDeadlineSeconds(depl *appsv1.Deployment) int32 {
+	if depl.Spec.ProgressDeadlineSeconds != nil {
+		return *depl.Spec.ProgressDeadlineSeconds
+	}
+
+	// the default progress deadline of a deployment
+	return 600
+}
+
+func getReplicas(replicas *int32) int32 {
+	if replicas != nil {
+		return *replicas
+	}
+
+	return 1
+}
+
+// deploymentConditionsChangedPredicate drops the updates of deployments whose conditions did not
+// change, such as the updates of the replica counts of a healthy deployment, since only a change
+// of the Progressing condition can open or resolve a rollout incident
+func deploymentConditionsChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldDepl, ok := e.ObjectOld.(*appsv1.Deployment)
+			if !ok {
+				return true
+			}
+
+			newDepl, ok := e.ObjectNew.(*appsv1.Deployment)
+			if !ok {
+				return true
+			}
+
+			return deploymentConditionsChanged(oldDepl, newDepl)
+		},
+	}
+}
+
+// deploymentConditionsChanged returns true if the type, status or reason of any condition of the
+// deployment changed
+func deploymentConditionsChanged(oldDepl, newDepl *appsv1.Deployment) bool {
+	if len(oldDepl.Status.Conditions) != len(newDepl.Status.Conditions) {
+		return true
+	}
+
+	for i, oldCondition := range oldDepl.Status.Conditions {
+		newCondition := newDepl.Status.Conditions[i]
+
+		if oldCondition.Type != newCondition.Type || oldCondition.Status != newCondition.Status ||
+			oldCondition.Reason != newCondition.Reason {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&appsv1.Deployment{}, builder.WithPredicates(deploymentConditionsChangedPredicate())).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, enqueueNamespaceObjects(mgr.GetClient(), &appsv1.DeploymentList{}),
+			builder.WithPredicates(namespaceSelectedPredicate())).
+		WithEventFilter(namespacePredicate(mgr.GetClient())).
+		Complete(r)
+}
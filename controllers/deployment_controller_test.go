@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestDeploymentConditionsChanged(t *testing.T) {
+	newDeployment := func(readyReplicas int32, conditions ...appsv1.DeploymentCondition) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			Status: appsv1.DeploymentStatus{
+				ReadyReplicas: readyReplicas,
+				Conditions:    conditions,
+			},
+		}
+	}
+
+	available := appsv1.DeploymentCondition{
+		Type:   appsv1.DeploymentAvailable,
+		Status: corev1.ConditionTrue,
+		Reason: "MinimumReplicasAvailable",
+	}
+
+	progressing := appsv1.DeploymentCondition{
+		Type:    appsv1.DeploymentProgressing,
+		Status:  corev1.ConditionTrue,
+		Reason:  "NewReplicaSetAvailable",
+		Message: `ReplicaSet "web-5d8f" has successfully progressed.`,
+	}
+
+	progressingUpdated := progressing
+	progressingUpdated.Message = `ReplicaSet "web-7c9a" has successfully progressed.`
+
+	deadlineExceeded := appsv1.DeploymentCondition{
+		Type:   appsv1.DeploymentProgressing,
+		Status: corev1.ConditionFalse,
+		Reason: "ProgressDeadlineExceeded",
+	}
+
+	tests := []struct {
+		name     string
+		oldDepl  *appsv1.Deployment
+		newDepl  *appsv1.Deployment
+		expected bool
+	}{
+		{
+			name:    "replicas changed",
+			oldDepl: newDeployment(2, available, progressing),
+			newDepl: newDeployment(3, available, progressing),
+		},
+		{
+			name:    "message changed",
+			oldDepl: newDeployment(3, available, progressing),
+			newDepl: newDeployment(3, available, progressingUpdated),
+		},
+		{
+			name:     "deadline exceeded",
+			oldDepl:  newDeployment(3, available, progressing),
+			newDepl:  newDeployment(3, available, deadlineExceeded),
+			expected: true,
+		},
+		{
+			name:     "rollout recovered",
+			oldDepl:  newDeployment(3, available, deadlineExceeded),
+			newDepl:  newDeployment(3, available, progressing),
+			expected: true,
+		},
+		{
+			name:     "condition added",
+			oldDepl:  newDeployment(0, progressing),
+			newDepl:  newDeployment(1, available, progressing),
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if changed := deploymentConditionsChanged(test.oldDepl, test.newDepl); changed != test.expected {
+				t.Errorf("expected changed: %t, got %t", test.expected, changed)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/porter-dev/porter-agent/pkg/utils"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// the number of restarts kept in the restart history of a flapping event
+const maxRestartHistoryDetails = 10
+
+var (
+	flappingWindow           time.Duration
+	flappingRestartThreshold int32
+)
+
+func init() {
+	viper.SetDefault("FLAPPING_WINDOW", "30m")
+	viper.SetDefault("FLAPPING_RESTART_THRESHOLD", 5)
+	viper.AutomaticEnv()
+
+	flappingWindow = viper.GetDuration("FLAPPING_WINDOW")
+	flappingRestartThreshold = viper.GetInt32("FLAPPING_RESTART_THRESHOLD")
+}
+
+// recordRestarts records the restart counts of the containers of the pod which changed since they
+// were recorded last, and returns the restart history of the release within the window. The history
+// is the only record of restarts, which both flapping detection and the restart threshold of
+// incident policies count from.
+func (r *PodReconciler) recordRestarts(
+	ctx context.Context, pod *corev1.Pod, releaseName string, window time.Duration,
+) ([]*models.RestartSample, error) {
+	now := time.Now()
+
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			last, found, err := r.Store.GetLastRestartCount(ctx, releaseName, pod.Namespace, pod.Name, status.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			if found && last == status.RestartCount {
+				continue
+			}
+
+			sample := &models.RestartSample{
+				PodName:       pod.Name,
+				ContainerName: status.Name,
+				RestartCount:  status.RestartCount,
+				Timestamp:     now.Unix(),
+			}
+
+			if found && last < status.RestartCount {
+				sample.Restarts = status.RestartCount - last
+			} else if found || now.Sub(pod.CreationTimestamp.Time) <= window {
+				// the count was reset by a new pod with the same name, or the restarts of a new pod
+				// happened before it was first seen
+				sample.Restarts = status.RestartCount
+			}
+
+			if terminated := status.LastTerminationState.Terminated; sample.Restarts > 0 && terminated != nil &&
+				!terminated.FinishedAt.IsZero() {
+				sample.Timestamp = terminated.FinishedAt.Unix()
+			}
+
+			err = r.Store.AddRestartSample(ctx, releaseName, pod.Namespace, sample, window)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return r.Store.GetRestartHistory(ctx, releaseName, pod.Namespace, now.Add(-window))
+}
+
+// filterRestartHistory returns the samples of the history taken since the given time
+func filterRestartHistory(history []*models.RestartSample, since time.Time) []*models.RestartSample {
+	var res []*models.RestartSample
+
+	for _, sample := range history {
+		if sample.Timestamp >= since.Unix() {
+			res = append(res, sample)
+		}
+	}
+
+	return res
+}
+
+// countPodRestarts returns the number of restarts of the containers of the pod since the given time
+func countPodRestarts(history []*models.RestartSample, podName string, since time.Time) int32 {
+	var restarts int32
+
+	for _, sample := range filterRestartHistory(history, since) {
+		if sample.PodName == podName {
+			restarts += sample.Restarts
+		}
+	}
+
+	return restarts
+}
+
+// getFlappingResult returns the result for a pod whose containers restarted within the flapping
+// window, if the containers of its release restarted at least as often as the threshold. Such pods
+// may be running whenever they are checked, so they are not caught by the pod filter. The details
+// leave out the number and times of the restarts, which change with every restart and would keep
+// the event from being recognized as a duplicate. They are kept in the restart history of the event
+// instead.
+func getFlappingResult(pod *corev1.Pod, history []*models.RestartSample) *utils.FilteredMessageResult {
+	if flappingRestartThreshold <= 0 {
+		return nil
+	}
+
+	var total int32
+	podRestarts := make(map[string]int32)
+
+	for _, sample := range history {
+		total += sample.Restarts
+
+		if sample.PodName == pod.Name {
+			podRestarts[sample.ContainerName] += sample.Restarts
+		}
+	}
+
+	if total < flappingRestartThreshold || len(podRestarts) == 0 {
+		return nil
+	}
+
+	res := &utils.FilteredMessageResult{
+		PodSummary: "The application is restarting repeatedly",
+		PodDetails: fmt.Sprintf("The containers of the application restarted at least %d times within the last %s, "+
+			"even though they were running in between.", flappingRestartThreshold, flappingWindow),
+		Severity: models.SeverityHigh,
+	}
+
+	for i, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if _, ok := podRestarts[status.Name]; !ok {
+				continue
+			}
+
+			containerRes := &utils.FilteredMessageContainerResult{
+				ContainerName: status.Name,
+				IsInit:        i == 0,
+				Summary:       "The container is restarting repeatedly",
+				Details: fmt.Sprintf("The container %s of pod %s restarted repeatedly within the last %s.",
+					status.Name, pod.Name, flappingWindow),
+				Severity: models.SeverityHigh,
+			}
+
+			if terminated := status.LastTerminationState.Terminated; terminated != nil {
+				summary, _ := utils.DescribeExitCode(terminated.ExitCode, i == 0)
+
+				containerRes.ExitCode = terminated.ExitCode
+				containerRes.Signal = utils.GetExitCodeSignal(terminated.ExitCode)
+				containerRes.Details += fmt.Sprintf(" Its last run ended as follows: %s.", summary)
+			}
+
+			res.ContainerStatuses = append(res.ContainerStatuses, containerRes)
+		}
+	}
+
+	return res
+}
+
+// getLatestRestarts returns the latest samples of the history, latest first
+func getLatestRestarts(history []*models.RestartSample) []*models.RestartSample {
+	latest := make([]*models.RestartSample, len(history))
+	copy(latest, history)
+
+	sort.SliceStable(latest, func(i, j int) bool {
+		return latest[i].Timestamp > latest[j].Timestamp
+	})
+
+	if len(latest) > maxRestartHistoryDetails {
+		latest = latest[:maxRestartHistoryDetails]
+	}
+
+	return latest
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/porter-dev/porter-agent/pkg/models"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetFlappingResult(t *testing.T) {
+	now := time.Now()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-0"},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:         "web",
+					RestartCount: 6,
+					LastTerminationState: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{ExitCode: 1},
+					},
+				},
+			},
+		},
+	}
+
+	newHistory := func(restarts int) []*models.RestartSample {
+		var history []*models.RestartSample
+
+		for i := 1; i <= restarts; i++ {
+			history = append(history, &models.RestartSample{
+				PodName:       "web-0",
+				ContainerName: "web",
+				RestartCount:  int32(i),
+				Restarts:      1,
+				Timestamp:     now.Add(time.Duration(i-restarts) * time.Minute).Unix(),
+			})
+		}
+
+		return history
+	}
+
+	if res := getFlappingResult(pod, newHistory(int(flappingRestartThreshold)-1)); res != nil {
+		t.Fatalf("expected no result below the restart threshold, got %q", res.PodSummary)
+	}
+
+	res := getFlappingResult(pod, newHistory(int(flappingRestartThreshold)))
+	if res == nil || len(res.ContainerStatuses) != 1 {
+		t.Fatalf("expected a result for the container at the restart threshold, got %v", res)
+	}
+
+	// further restarts must not change the event, so that it is recognized as a duplicate
+	further := getFlappingResult(pod, newHistory(int(flappingRestartThreshold)+1))
+	if further == nil || len(further.ContainerStatuses) != 1 {
+		t.Fatalf("expected a result for the container after a further restart, got %v", further)
+	}
+
+	if further.PodDetails != res.PodDetails {
+		t.Errorf("expected details %q after a further restart, got %q", res.PodDetails, further.PodDetails)
+	}
+
+	if further.ContainerStatuses[0].Details != res.ContainerStatuses[0].Details {
+		t.Errorf("expected container details %q after a further restart, got %q",
+			res.ContainerStatuses[0].Details, further.ContainerStatuses[0].Details)
+	}
+}
+
+func TestGetLatestRestarts(t *testing.T) {
+	var history []*models.RestartSample
+
+	for i := 0; i < maxRestartHistoryDetails+5; i++ {
+		history = append(history, &models.RestartSample{RestartCount: int32(i), Timestamp: int64(i)})
+	}
+
+	latest := getLatestRestarts(history)
+
+	if len(latest) != maxRestartHistoryDetails {
+		t.Fatalf("expected %d restarts, got %d", maxRestartHistoryDetails, len(latest))
+	}
+
+	if latest[0].Timestamp != int64(len(history)-1) {
+		t.Errorf("expected the latest restart first, got timestamp %d", latest[0].Timestamp)
+	}
+
+	if history[0].Timestamp != 0 {
+		t.Errorf("expected the history to be left unsorted, got timestamp %d first", history[0].Timestamp)
+	}
+}
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/porter-dev/porter-agent/pkg/store"
+	"github.com/porter-dev/porter-agent/pkg/utils"
+	"github.com/spf13/viper"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var hpaMaxReplicasDuration time.Duration
+
+func init() {
+	viper.SetDefault("HPA_MAX_REPLICAS_DURATION", "15m")
+	viper.AutomaticEnv()
+
+	hpaMaxReplicasDuration = viper.GetDuration("HPA_MAX_REPLICAS_DURATION")
+}
+
+// only metric failures reported within this window are considered ongoing
+const hpaMetricEventWindow = 5 * time.Minute
+
+// HPAReconciler reconciles a HorizontalPodAutoscaler object
+type HPAReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	Store           store.IncidentStore
+	KubeClient      kubernetes.Interface
+	ReleaseResolver *utils.ReleaseResolver
+
+	logger logr.Logger
+}
+
+//+kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch
+
+// Reconcile opens an incident for the release of an autoscaler which has been running at its
+// maximum number of replicas for too long, is unable to scale or cannot fetch its metrics, and
+// resolves it once the autoscaler is healthy again.
+func (r *HPAReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = log.FromContext(ctx)
+
+	instance := &autoscalingv2.HorizontalPodAutoscaler{}
+	err := r.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	porterReleaseName := r.ReleaseResolver.GetAutoscalerReleaseName(ctx, instance)
+
+	// we ignore autoscalers which have an empty release name, same as pods
+	if porterReleaseName == "" {
+		return ctrl.Result{}, nil
+	}
+
+	member := objectMember(string(models.HPAResource), instance.Name)
+
+	agentCreationTimestamp, err := r.Store.GetAgentCreationTimestamp(ctx)
+	if err != nil {
+		r.logger.Error(err, "incidentStore.GetAgentCreationTimestamp ERROR")
+		return ctrl.Result{}, err
+	}
+
+	summary, details, requeueAfter := r.getAutoscalerProblem(ctx, instance, time.Unix(agentCreationTimestamp, 0))
+
+	if summary == "" {
+		err = resolveActiveIncidentMember(ctx, r.Store, porterReleaseName, instance.Namespace, member)
+		if err != nil {
+			r.logger.Error(err, "error resolving autoscaler incident", "hpa", instance.Name)
+			return ctrl.Result{Requeue: true}, err
+		}
+
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	event := &models.PodEvent{
+		ChartName: instance.Labels["helm.sh/chart"],
+		PodName:   member,
+		Namespace: instance.Namespace,
+		OwnerName: porterReleaseName,
+		OwnerType: string(models.HPAResource),
+		Timestamp: time.Now().Unix(),
+		Reason:    summary,
+		Message:   details,
+		Severity:  models.SeverityMedium,
+	}
+
+	added, err := addEventToActiveIncident(ctx, r.Client, r.Store, porterReleaseName, instance.Namespace, event)
+	if err != nil {
+		r.logger.Error(err, "error adding event to autoscaler incident", "hpa", instance.Name)
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	if added {
+		r.logger.Info("added autoscaler event to incident", "hpa", instance.Name, "reason", summary)
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// getAutoscalerProblem returns the summary and details of the first problem found with the
+// autoscaler, if any, along with the time after which it should be checked again when the
+// problem cannot be observed through changes to the autoscaler itself. Like pods created before
+// the agent, problems which began before the agent was created are not reported.
+func (r *HPAReconciler) getAutoscalerProblem(
+	ctx context.Context, hpa *autoscalingv2.HorizontalPodAutoscaler, agentCreatedAt time.Time,
+) (string, string, time.Duration) {
+	for _, condition := range hpa.Status.Conditions {
+		if condition.Status != corev1.ConditionFalse || condition.LastTransitionTime.Time.Before(agentCreatedAt) {
+			continue
+		}
+
+		if condition.Type == autoscalingv2.AbleToScale {
+			return "The autoscaler is unable to scale the application",
+				fmt.Sprintf("The autoscaler %s is unable to scale the application: %s", hpa.Name, condition.Message), 0
+		} else if condition.Type == autoscalingv2.ScalingActive {
+			if condition.Reason == "FailedGetResourceMetric" {
+				return "The autoscaler could not fetch resource metrics", getFailedMetricDetails(hpa.Name, condition.Message), 0
+			}
+
+			return "Autoscaling is not active for the application",
+				fmt.Sprintf("The autoscaler %s is not able to compute the number of replicas: %s", hpa.Name, condition.Message), 0
+		}
+	}
+
+	event := r.getLatestEventForReason(ctx, hpa, "FailedGetResourceMetric")
+	if event != nil && time.Since(event.LastTimestamp.Time) < hpaMetricEventWindow &&
+		!event.LastTimestamp.Time.Before(agentCreatedAt) {
+		// metric failures are only reported through events, so check again once this one is stale
+		return "The autoscaler could not fetch resource metrics", getFailedMetricDetails(hpa.Name, event.Message),
+			time.Until(event.LastTimestamp.Add(hpaMetricEventWindow))
+	}
+
+	if hpa.Status.CurrentReplicas < hpa.Spec.MaxReplicas {
+		return "", "", 0
+	}
+
+	// the autoscaler has been at its maximum since it last scaled
+	since := hpa.CreationTimestamp.Time
+
+	if hpa.Status.LastScaleTime != nil {
+		since = hpa.Status.LastScaleTime.Time
+	}
+
+	if since.Before(agentCreatedAt) {
+		return "", "", 0
+	}
+
+	if remaining := time.Until(since.Add(hpaMaxReplicasDuration)); remaining > 0 {
+		return "", "", remaining
+	}
+
+	details := fmt.Sprintf("The autoscaler %s has been running the application at its maximum of %d replicas "+
+		"since %s. The application may not have enough capacity to handle its load - consider increasing "+
+		"the maximum number of replicas or the resources available to each replica.",
+		hpa.Name, hpa.Spec.MaxReplicas, since.UTC().Format(time.RFC1123))
+
+	return "The application is running at its maximum number of replicas", details, 0
+}
+
+func (r *HPAReconciler) getLatestEventForReason(
+	ctx context.Context, hpa *autoscalingv2.HorizontalPodAutoscaler, reason string,
+) *corev1.Event {
+	events, err := r.KubeClient.CoreV1().Events(hpa.Namespace).List(
+		ctx, metav1.ListOptions{
+			FieldSelector: fmt.Sprintf(
+				"involvedObject.kind=HorizontalPodAutoscaler,involvedObject.name=%s,reason=%s",
+				hpa.Name, reason),
+		},
+	)
+
+	if err != nil || len(events.Items) == 0 {
+		return nil
+	}
+
+	latest := &events.Items[0]
+
+	for i := range events.Items {
+		if events.Items[i].LastTimestamp.After(latest.LastTimestamp.Time) {
+			latest = &events.Items[i]
+		}
+	}
+
+	return latest.DeepCopy()
+}
+
+func getFailedMetricDetails(hpaName, message string) string {
+	return fmt.Sprintf("The autoscaler %s could not fetch the resource metrics of the application: %s. "+
+		"Make sure that the metrics server is running in the cluster and that resource requests are set "+
+		"for all containers of the application.", hpaName, message)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HPAReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&autoscalingv2.HorizontalPodAutoscaler{}).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, enqueueNamespaceObjects(mgr.GetClient(), &autoscalingv2.HorizontalPodAutoscalerList{}),
+			builder.WithPredicates(namespaceSelectedPredicate())).
+		WithEventFilter(namespacePredicate(mgr.GetClient())).
+		Complete(r)
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetAutoscalerProblem(t *testing.T) {
+	now := time.Now()
+	agentCreatedAt := now.Add(-time.Hour)
+
+	newHPA := func(currentReplicas int32, lastScaleTime time.Time, conditions ...autoscalingv2.HorizontalPodAutoscalerCondition) *autoscalingv2.HorizontalPodAutoscaler {
+		scaled := metav1.NewTime(lastScaleTime)
+
+		return &autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "web",
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(now.Add(-24 * time.Hour)),
+			},
+			Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+				MaxReplicas: 10,
+			},
+			Status: autoscalingv2.HorizontalPodAutoscalerStatus{
+				CurrentReplicas: currentReplicas,
+				LastScaleTime:   &scaled,
+				Conditions:      conditions,
+			},
+		}
+	}
+
+	scalingInactive := func(transitioned time.Time) autoscalingv2.HorizontalPodAutoscalerCondition {
+		return autoscalingv2.HorizontalPodAutoscalerCondition{
+			Type:               autoscalingv2.ScalingActive,
+			Status:             corev1.ConditionFalse,
+			Reason:             "InvalidSelector",
+			Message:            "the selector is invalid",
+			LastTransitionTime: metav1.NewTime(transitioned),
+		}
+	}
+
+	tests := []struct {
+		name                 string
+		hpa                  *autoscalingv2.HorizontalPodAutoscaler
+		expectedSummary      string
+		expectedRequeueAfter bool
+	}{
+		{
+			name:            "scaling not active",
+			hpa:             newHPA(3, now.Add(-2*time.Hour), scalingInactive(now.Add(-time.Minute))),
+			expectedSummary: "Autoscaling is not active for the application",
+		},
+		{
+			name: "scaling not active before agent was created",
+			hpa:  newHPA(3, now.Add(-2*time.Hour), scalingInactive(agentCreatedAt.Add(-time.Minute))),
+		},
+		{
+			name:            "at max replicas",
+			hpa:             newHPA(10, now.Add(-hpaMaxReplicasDuration-time.Minute)),
+			expectedSummary: "The application is running at its maximum number of replicas",
+		},
+		{
+			name:                 "at max replicas within duration",
+			hpa:                  newHPA(10, now.Add(-time.Minute)),
+			expectedRequeueAfter: true,
+		},
+		{
+			name: "at max replicas before agent was created",
+			hpa:  newHPA(10, agentCreatedAt.Add(-time.Minute)),
+		},
+		{
+			name: "below max replicas",
+			hpa:  newHPA(3, now.Add(-2*time.Hour)),
+		},
+	}
+
+	r := &HPAReconciler{KubeClient: fake.NewSimpleClientset()}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			summary, _, requeueAfter := r.getAutoscalerProblem(context.Background(), test.hpa, agentCreatedAt)
+
+			if summary != test.expectedSummary {
+				t.Errorf("expected summary %q, got %q", test.expectedSummary, summary)
+			}
+
+			if (requeueAfter > 0) != test.expectedRequeueAfter {
+				t.Errorf("expected requeue: %t, got requeue after %s", test.expectedRequeueAfter, requeueAfter)
+			}
+		})
+	}
+}
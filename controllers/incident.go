@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	porterErrors "github.com/porter-dev/porter-agent/pkg/errors"
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/porter-dev/porter-agent/pkg/policy"
+	"github.com/porter-dev/porter-agent/pkg/store"
+	"github.com/porter-dev/porter-agent/pkg/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// objects other than pods are tracked in the "pods:<incident_id>" set as "<kind>/<name>",
+// which can never clash with a pod name
+func objectMember(kind, name string) string {
+	return fmt.Sprintf("%s/%s", strings.ToLower(kind), name)
+}
+
+func isObjectMember(member string) bool {
+	return strings.Contains(member, "/")
+}
+
+// addEventToActiveIncident adds the event to the active incident of the release, creating a new
+// incident if none is active. It returns false if the event was dropped because it is the same
+// as the latest event of the incident for the same object, or because the incidents of the
+// release are suppressed by its incident policy.
+func addEventToActiveIncident(
+	ctx context.Context, c client.Reader, incidentStore store.IncidentStore, releaseName, namespace string,
+	event *models.PodEvent,
+) (bool, error) {
+	if apply, err := applyIncidentPolicy(ctx, c, releaseName, namespace, event); err != nil || !apply {
+		return false, err
+	}
+
+	redactEvent(event)
+
+	incidentID, newIncident, err := incidentStore.GetOrCreateActiveIncident(ctx, releaseName, namespace)
+	if err != nil {
+		return false, err
+	}
+
+	if !newIncident {
+		if duplicate, err := isDuplicateEvent(ctx, incidentStore, incidentID, event); err != nil || duplicate {
+			return false, err
+		}
+	}
+
+	return addEventToIncident(ctx, incidentStore, incidentID, event, newIncident)
+}
+
+// isDuplicateEvent returns true if the latest event of the incident for the same object has the
+// same reason and message as the event.
+func isDuplicateEvent(ctx context.Context, incidentStore store.IncidentStore, incidentID string, event *models.PodEvent) (bool, error) {
+	events, err := incidentStore.GetIncidentEventsByID(ctx, incidentID)
+	if err != nil {
+		return false, err
+	}
+
+	// events are sorted latest first
+	for _, latestEvent := range events {
+		if latestEvent.PodName != event.PodName {
+			continue
+		}
+
+		return latestEvent.Reason == event.Reason && latestEvent.Message == event.Message, nil
+	}
+
+	return false, nil
+}
+
+// the number of times an event is added to a new incident when the incident it was meant for is
+// resolved concurrently
+const maxIncidentAttempts = 3
+
+// addEventToIncident adds the event, which has to be redacted already, to the incident, returning
+// false if the incident already holds the maximum number of events. If the incident was resolved in
+// the meantime, the event is added to the active incident of the release instead, along with the
+// logs which were added to the incident for the event.
+func addEventToIncident(
+	ctx context.Context, incidentStore store.IncidentStore, incidentID string, event *models.PodEvent, newIncident bool,
+) (bool, error) {
+	for attempt := 0; ; attempt++ {
+		err := incidentStore.AddEventToIncident(ctx, incidentID, event, newIncident)
+		if errors.Is(err, porterErrors.MaxEventCountError) {
+			return false, nil
+		} else if !errors.Is(err, porterErrors.IncidentNotActiveError) || attempt >= maxIncidentAttempts {
+			return err == nil, err
+		}
+
+		incidentObj, err := utils.NewIncidentFromString(incidentID)
+		if err != nil {
+			return false, err
+		}
+
+		incidentID, newIncident, err = incidentStore.GetOrCreateActiveIncident(ctx, incidentObj.GetReleaseName(), incidentObj.GetNamespace())
+		if err != nil {
+			return false, err
+		}
+
+		if err := addEventLogsToIncident(ctx, incidentStore, incidentID, event); err != nil {
+			return false, err
+		}
+	}
+}
+
+// addEventLogsToIncident adds the logs of the event to the incident. Logs are keyed by their
+// content, so the event keeps referring to the same logs.
+func addEventLogsToIncident(ctx context.Context, incidentStore store.IncidentStore, incidentID string, event *models.PodEvent) error {
+	for _, containerEvent := range event.ContainerEvents {
+		if containerEvent.LogID == "" {
+			continue
+		}
+
+		logs, err := incidentStore.GetLogs(ctx, containerEvent.LogID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := incidentStore.AddLogs(ctx, incidentID, logs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyIncidentPolicy sets the severity of the incident policy of the release on the event. It
+// returns false if the incidents of the release are suppressed by the policy, in which case the
+// event must not be added to an incident.
+func applyIncidentPolicy(
+	ctx context.Context, c client.Reader, releaseName, namespace string, event *models.PodEvent,
+) (bool, error) {
+	incidentPolicy, err := policy.GetIncidentPolicy(ctx, c, namespace, releaseName)
+	if err != nil {
+		return false, fmt.Errorf("error fetching incident policy for release %s in namespace %s: %w",
+			releaseName, namespace, err)
+	} else if incidentPolicy == nil {
+		return true, nil
+	}
+
+	if incidentPolicy.Spec.Suppress {
+		log.FromContext(ctx).Info("suppressing incident by policy", "policy", incidentPolicy.Name,
+			"release", releaseName, "namespace", namespace)
+		return false, nil
+	}
+
+	// the severity of a policy takes precedence over the computed severity
+	if incidentPolicy.Spec.Severity != "" {
+		event.Severity = models.Severity(incidentPolicy.Spec.Severity)
+		event.PolicySeverity = event.Severity
+	}
+
+	return true, nil
+}
+
+// redactEvent redacts the secrets in the reasons and messages of the event. Events are redacted
+// before they are compared to the stored events, which are redacted as well.
+func redactEvent(event *models.PodEvent) {
+	event.Reason, _ = utils.RedactSecrets(event.Reason)
+	event.Message, _ = utils.RedactSecrets(event.Message)
+
+	for _, containerEvent := range event.ContainerEvents {
+		containerEvent.Reason, _ = utils.RedactSecrets(containerEvent.Reason)
+		containerEvent.Message, _ = utils.RedactSecrets(containerEvent.Message)
+	}
+
+	if event.Eviction != nil {
+		event.Eviction.Message, _ = utils.RedactSecrets(event.Eviction.Message)
+	}
+}
+
+// resolveActiveIncidentMember marks the member as resolved in the active incident of the release,
+// if it is part of it. The incident itself is resolved once none of its members are affected.
+func resolveActiveIncidentMember(
+	ctx context.Context, incidentStore store.IncidentStore, releaseName, namespace, member string,
+) error {
+	exists, err := incidentStore.ActiveIncidentExists(ctx, releaseName, namespace)
+	if err != nil || !exists {
+		return err
+	}
+
+	incidentID, err := incidentStore.GetActiveIncident(ctx, releaseName, namespace)
+	if err != nil {
+		return err
+	}
+
+	members, err := incidentStore.GetPodsForIncident(ctx, incidentID)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range members {
+		if m == member {
+			return incidentStore.SetPodResolved(ctx, member, incidentID)
+		}
+	}
+
+	return nil
+}
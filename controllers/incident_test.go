@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/porter-dev/porter-agent/pkg/redis"
+	"github.com/porter-dev/porter-agent/pkg/store"
+	"github.com/porter-dev/porter-agent/pkg/utils"
+)
+
+func TestAddEventToResolvedIncidentKeepsLogs(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	incidentStore := &store.RedisStore{Client: redis.NewClient(server.Host(), server.Port(), "", "", redis.PODSTORE, 100)}
+	ctx := context.Background()
+
+	// the incident was created earlier, since incidents created within the same second share their ID
+	incidentID := utils.NewIncident("web", "default", time.Now().Add(-time.Hour).Unix()).ToString()
+	server.Set("active_incident:web:default", incidentID)
+
+	firstEvent := &models.PodEvent{PodName: "web-1", Namespace: "default", Timestamp: time.Now().Unix(), Reason: "Error"}
+
+	if err := incidentStore.AddEventToIncident(ctx, incidentID, firstEvent, true); err != nil {
+		t.Fatalf("error adding event: %v", err)
+	}
+
+	logID, err := incidentStore.AddLogs(ctx, incidentID, "panic: runtime error")
+	if err != nil {
+		t.Fatalf("error adding logs: %v", err)
+	}
+
+	// the incident is resolved after the logs of the event were added to it
+	if err := incidentStore.SetJobIncidentResolved(ctx, incidentID); err != nil {
+		t.Fatalf("error resolving incident: %v", err)
+	}
+
+	event := &models.PodEvent{
+		PodName:   "web-0",
+		Namespace: "default",
+		Timestamp: time.Now().Unix(),
+		Reason:    "Error",
+		ContainerEvents: map[string]*models.ContainerEvent{
+			"web": {LogID: logID},
+		},
+	}
+
+	added, err := addEventToIncident(ctx, incidentStore, incidentID, event, false)
+	if err != nil || !added {
+		t.Fatalf("expected event to be added to a new incident, got added: %t, error: %v", added, err)
+	}
+
+	newIncidentID, err := incidentStore.GetActiveIncident(ctx, "web", "default")
+	if err != nil {
+		t.Fatalf("error fetching active incident: %v", err)
+	} else if newIncidentID == incidentID {
+		t.Fatalf("expected a new incident after %s was resolved", incidentID)
+	}
+
+	logIDs, err := incidentStore.GetLogIDsForIncident(ctx, newIncidentID)
+	if err != nil {
+		t.Fatalf("error fetching log IDs: %v", err)
+	}
+
+	if len(logIDs) != 1 || logIDs[0].LogID != logID {
+		t.Errorf("expected logs %s in the new incident, got %v", logID, logIDs)
+	}
+}
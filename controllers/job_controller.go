@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/porter-dev/porter-agent/pkg/store"
+	"github.com/porter-dev/porter-agent/pkg/utils"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// JobReconciler reconciles a Job object
+type JobReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	Store           store.IncidentStore
+	KubeClient      *kubernetes.Clientset
+	PodFilter       utils.PodFilter
+	ReleaseResolver *utils.ReleaseResolver
+
+	logger logr.Logger
+}
+
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch
+
+// Reconcile opens an incident for the release of a job once the job has failed, either because it
+// ran out of retries or because it exceeded its deadline. The incident is resolved once a later
+// run of the release completes.
+func (r *JobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = log.FromContext(ctx)
+
+	instance := &batchv1.Job{}
+	err := r.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	porterReleaseName := r.ReleaseResolver.GetReleaseName(ctx, instance)
+
+	// we ignore jobs which have an empty release name, same as pods
+	if porterReleaseName == "" {
+		return ctrl.Result{}, nil
+	}
+
+	agentCreationTimestamp, err := r.Store.GetAgentCreationTimestamp(ctx)
+	if err != nil {
+		r.logger.Error(err, "incidentStore.GetAgentCreationTimestamp ERROR")
+		return ctrl.Result{}, err
+	}
+
+	if instance.GetCreationTimestamp().Unix() < agentCreationTimestamp {
+		return ctrl.Result{}, nil
+	}
+
+	if completed := getJobCondition(instance, batchv1.JobComplete); completed != nil {
+		return ctrl.Result{}, r.resolveJobIncident(ctx, instance, porterReleaseName)
+	}
+
+	failed := getJobCondition(instance, batchv1.JobFailed)
+	if failed == nil {
+		return ctrl.Result{}, nil
+	}
+
+	if owner := metav1.GetControllerOf(instance); owner != nil && owner.Kind == "CronJob" {
+		// failed runs of a cron job are reported by the CronJobReconciler as consecutive failures
+		return ctrl.Result{}, nil
+	}
+
+	if superseded, err := r.hasLaterCompletedRun(ctx, instance, porterReleaseName, failed.LastTransitionTime.Time); err != nil {
+		return ctrl.Result{Requeue: true}, err
+	} else if superseded {
+		return ctrl.Result{}, nil
+	}
+
+	event := &models.PodEvent{
+		ChartName:       instance.Labels["helm.sh/chart"],
+		PodName:         objectMember(string(models.JobResource), instance.Name),
+		Namespace:       instance.Namespace,
+		OwnerName:       porterReleaseName,
+		OwnerType:       string(models.JobResource),
+		Timestamp:       time.Now().Unix(),
+		Phase:           string(batchv1.JobFailed),
+		Severity:        models.SeverityMedium,
+		ContainerEvents: make(map[string]*models.ContainerEvent),
+	}
+
+	attempts := instance.Status.Failed + instance.Status.Succeeded
+
+	switch failed.Reason {
+	case "BackoffLimitExceeded":
+		event.Reason = fmt.Sprintf("The job failed after %d attempts", attempts)
+	case "DeadlineExceeded":
+		event.Reason = "The job did not complete before its deadline"
+	default:
+		event.Reason = "The job failed"
+	}
+
+	event.Message = fmt.Sprintf("The job %s failed: %s. %d of %d allowed attempts failed.",
+		instance.Name, strings.TrimSuffix(failed.Message, "."), instance.Status.Failed, getJobMaxAttempts(instance))
+
+	err = reportJobFailure(ctx, r.Client, r.KubeClient, r.Store, r.PodFilter, instance, porterReleaseName, event)
+	if err != nil {
+		r.logger.Error(err, "error reporting failed job", "job", instance.Name)
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *JobReconciler) resolveJobIncident(ctx context.Context, job *batchv1.Job, releaseName string) error {
+	exists, err := r.Store.ActiveIncidentExists(ctx, releaseName, job.Namespace)
+	if err != nil || !exists {
+		return err
+	}
+
+	incidentID, err := r.Store.GetActiveIncident(ctx, releaseName, job.Namespace)
+	if err != nil {
+		return err
+	}
+
+	incidentObj, err := utils.NewIncidentFromString(incidentID)
+	if err != nil {
+		return err
+	}
+
+	// only a run which completed after the incident was opened resolves it
+	if job.Status.CompletionTime == nil || job.Status.CompletionTime.Time.Before(incidentObj.GetTimestampAsTime()) {
+		return nil
+	}
+
+	r.logger.Info("resolving job incident", "job", job.Name, "incidentID", incidentID)
+
+	return r.Store.SetJobIncidentResolved(ctx, incidentID)
+}
+
+// reportJobFailure adds the event for the failed job to the active incident of the release, along
+// with the container events and logs of the final failed pod of the job. The event is dropped if
+// it is the same as the latest event for the same object.
+func reportJobFailure(
+	ctx context.Context, c client.Client, kubeClient *kubernetes.Clientset, incidentStore store.IncidentStore,
+	podFilter utils.PodFilter, job *batchv1.Job, releaseName string, event *models.PodEvent,
+) error {
+	logger := log.FromContext(ctx)
+
+	finalPod, err := getFinalFailedPod(ctx, c, job)
+	if err != nil {
+		return fmt.Errorf("error fetching pods of job %s: %w", job.Name, err)
+	}
+
+	if finalPod != nil {
+		if filteredMsgRes := podFilter.Filter(finalPod, true); filteredMsgRes != nil {
+			event.Message += fmt.Sprintf(" The last attempt ran in pod %s: %s", finalPod.Name, filteredMsgRes.PodDetails)
+			event.Severity = models.MaxSeverity(event.Severity, filteredMsgRes.Severity)
+
+			for _, filteredContainerRes := range filteredMsgRes.ContainerStatuses {
+				event.ContainerEvents[filteredContainerRes.ContainerName] = &models.ContainerEvent{
+					Name:          filteredContainerRes.ContainerName,
+					InitContainer: filteredContainerRes.IsInit,
+					Reason:        filteredContainerRes.Summary,
+					Message:       filteredContainerRes.Details,
+					ExitCode:      filteredContainerRes.ExitCode,
+					Signal:        filteredContainerRes.Signal,
+				}
+			}
+		}
+	}
+
+	if apply, err := applyIncidentPolicy(ctx, c, releaseName, job.Namespace, event); err != nil || !apply {
+		return err
+	}
+
+	redactEvent(event)
+
+	incidentID, newIncident, err := incidentStore.GetOrCreateActiveIncident(ctx, releaseName, job.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if !newIncident {
+		if duplicate, err := isDuplicateEvent(ctx, incidentStore, incidentID, event); err != nil || duplicate {
+			return err
+		}
+	}
+
+	for containerName, containerEvent := range event.ContainerEvents {
+		strLogs, err := getContainerLogs(ctx, kubeClient, finalPod, containerName)
+		if err != nil {
+			// the pod may be gone already, which should not keep us from reporting the failure
+			logger.Error(err, "unable to read logs", "pod", finalPod.Name)
+			continue
+		}
+
+		if strLogs == "" || strings.Contains(strLogs, "unable to retrieve container logs") {
+			continue
+		}
+
+		strLogs, containerEvent.LogRedactions = utils.RedactSecrets(strLogs)
+
+		recordCrashSignature(containerEvent, strLogs)
+
+		logID, err := incidentStore.AddLogs(ctx, incidentID, strLogs)
+		if err != nil {
+			return fmt.Errorf("error adding logs of pod %s: %w", finalPod.Name, err)
+		}
+
+		containerEvent.LogID = logID
+	}
+
+	_, err = addEventToIncident(ctx, incidentStore, incidentID, event, newIncident)
+
+	return err
+}
+
+// hasLaterCompletedRun returns true if another job of the same release has completed
+// after the given time, in which case the failure has already been recovered from
+func (r *JobReconciler) hasLaterCompletedRun(
+	ctx context.Context, job *batchv1.Job, releaseName string, failedAt time.Time,
+) (bool, error) {
+	jobs := &batchv1.JobList{}
+
+	err := r.List(ctx, jobs, client.InNamespace(job.Namespace))
+	if err != nil {
+		return false, err
+	}
+
+	for i := range jobs.Items {
+		other := &jobs.Items[i]
+
+		if other.Name != job.Name && other.Status.CompletionTime != nil &&
+			other.Status.CompletionTime.After(failedAt) &&
+			r.ReleaseResolver.GetReleaseName(ctx, other) == releaseName {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// getFinalFailedPod returns the most recently created failed pod of the job, or the most recently
+// created pod if none of them failed, which can happen when the job is stopped by its deadline
+func getFinalFailedPod(ctx context.Context, c client.Client, job *batchv1.Job) (*corev1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	pods := &corev1.PodList{}
+
+	err = c.List(ctx, pods, client.InNamespace(job.Namespace), client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	var latest, latestFailed *corev1.Pod
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+
+		if latest == nil || pod.CreationTimestamp.After(latest.CreationTimestamp.Time) {
+			latest = pod
+		}
+
+		if pod.Status.Phase == corev1.PodFailed &&
+			(latestFailed == nil || pod.CreationTimestamp.After(latestFailed.CreationTimestamp.Time)) {
+			latestFailed = pod
+		}
+	}
+
+	if latestFailed != nil {
+		return latestFailed, nil
+	}
+
+	return latest, nil
+}
+
+func getJobCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) *batchv1.JobCondition {
+	for i := range job.Status.Conditions {
+		if job.Status.Conditions[i].Type == conditionType && job.Status.Conditions[i].Status == corev1.ConditionTrue {
+			return &job.Status.Conditions[i]
+		}
+	}
+
+	return nil
+}
+
+func getJobMaxAttempts(job *batchv1.Job) int32 {
+	// the default backoff limit of a job is 6 retries
+	backoffLimit := int32(6)
+
+	if job.Spec.BackoffLimit != nil {
+		backoffLimit = *job.Spec.BackoffLimit
+	}
+
+	return backoffLimit + 1
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *JobReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&batchv1.Job{}).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, enqueueNamespaceObjects(mgr.GetClient(), &batchv1.JobList{}),
+			builder.WithPredicates(namespaceSelectedPredicate())).
+		WithEventFilter(namespacePredicate(mgr.GetClient())).
+		Complete(r)
+}
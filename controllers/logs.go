@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/porter-dev/porter-agent/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// getContainerLogs returns the latest logs of the container, from its previous run
+// if it was restarted
+func getContainerLogs(
+	ctx context.Context, kubeClient *kubernetes.Clientset, pod *corev1.Pod, containerName string,
+) (string, error) {
+	logOptions := &corev1.PodLogOptions{
+		TailLines: &maxTailLines,
+		Previous:  hasLastTerminatedState(pod, containerName),
+		Container: containerName,
+	}
+
+	req := kubeClient.
+		CoreV1().
+		Pods(pod.Namespace).
+		GetLogs(pod.Name, logOptions)
+
+	podLogs, err := req.Stream(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer podLogs.Close()
+
+	logs := new(bytes.Buffer)
+	_, err = io.Copy(logs, podLogs)
+	if err != nil {
+		return "", err
+	}
+
+	return logs.String(), nil
+}
+
+// recordCrashSignature stores the crash found in the logs of the container on its event, which the
+// summary of the incident names. The message of the event is left as is, since events are
+// deduplicated by their message.
+func recordCrashSignature(containerEvent *models.ContainerEvent, strLogs string) {
+	containerEvent.CrashSignature = utils.ExtractCrashSignature(strLogs)
+}
+
+func hasLastTerminatedState(pod *corev1.Pod, containerName string) bool {
+	var statuses []corev1.ContainerStatus
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if containerName == statuses[i].Name {
+			if statuses[i].LastTerminationState.Waiting != nil ||
+				statuses[i].LastTerminationState.Terminated != nil {
+				return true
+			}
+
+			return false
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var (
+	includedNamespaces map[string]bool
+	excludedNamespaces map[string]bool
+	namespaceSelector  labels.Selector
+
+	namespaceLog = ctrl.Log.WithName("namespaces")
+)
+
+func init() {
+	viper.SetDefault("NAMESPACE_INCLUDE", "")
+	viper.SetDefault("NAMESPACE_EXCLUDE", "cert-manager,ingress-nginx,kube-node-lease,kube-public,kube-system,"+
+		"monitoring,porter-agent-system")
+	viper.SetDefault("NAMESPACE_SELECTOR", "")
+	viper.AutomaticEnv()
+
+	includedNamespaces = getNamespaceSet(viper.GetString("NAMESPACE_INCLUDE"))
+	excludedNamespaces = getNamespaceSet(viper.GetString("NAMESPACE_EXCLUDE"))
+
+	selector, err := labels.Parse(viper.GetString("NAMESPACE_SELECTOR"))
+	if err != nil {
+		panic(fmt.Sprintf("invalid namespace selector: %v", err))
+	}
+
+	namespaceSelector = selector
+}
+
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+// namespacePredicate drops the events of objects outside of the watched namespaces before
+// they reach the reconcile queue. A namespace is watched when it is in the include list, or
+// the include list is empty, when it is not in the exclude list and when its labels match
+// the namespace selector. The events of the namespaces themselves are let through, since
+// they are filtered by namespaceSelectedPredicate.
+func namespacePredicate(c client.Client) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		if _, ok := obj.(*corev1.Namespace); ok {
+			return true
+		}
+
+		return isWatchedNamespace(c, obj.GetNamespace())
+	})
+}
+
+// namespaceSelectedPredicate keeps the updates of namespaces whose labels start matching the
+// namespace selector, since the objects in them were dropped by namespacePredicate so far
+func namespaceSelectedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return !namespaceSelector.Empty() &&
+				!namespaceSelector.Matches(labels.Set(e.ObjectOld.GetLabels())) &&
+				namespaceSelector.Matches(labels.Set(e.ObjectNew.GetLabels()))
+		},
+		DeleteFunc: func(event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(event.GenericEvent) bool {
+			return false
+		},
+	}
+}
+
+// enqueueNamespaceObjects returns a handler which queues the objects of the type of the list in a
+// namespace once it is watched, so that label changes on namespaces apply without a restart
+func enqueueNamespaceObjects(c client.Client, list client.ObjectList) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		if !isWatchedNamespace(c, obj.GetName()) {
+			return nil
+		}
+
+		objects := list.DeepCopyObject().(client.ObjectList)
+
+		if err := c.List(context.Background(), objects, client.InNamespace(obj.GetName())); err != nil {
+			namespaceLog.Error(err, "error listing objects of namespace", "namespace", obj.GetName())
+			return nil
+		}
+
+		var requests []reconcile.Request
+
+		meta.EachListItem(objects, func(item runtime.Object) error {
+			if object, ok := item.(client.Object); ok {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()},
+				})
+			}
+
+			return nil
+		})
+
+		return requests
+	})
+}
+
+func isWatchedNamespace(c client.Client, namespace string) bool {
+	if len(includedNamespaces) > 0 && !includedNamespaces[namespace] {
+		return false
+	}
+
+	if excludedNamespaces[namespace] {
+		return false
+	}
+
+	if namespaceSelector.Empty() {
+		return true
+	}
+
+	ns := &corev1.Namespace{}
+
+	err := c.Get(context.Background(), types.NamespacedName{Name: namespace}, ns)
+	if err != nil {
+		return false
+	}
+
+	return namespaceSelector.Matches(labels.Set(ns.Labels))
+}
+
+func getNamespaceSet(namespaces string) map[string]bool {
+	res := make(map[string]bool)
+
+	for _, ns := range strings.Split(namespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			res[ns] = true
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/porter-dev/porter-agent/pkg/store"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// nodes are not namespaced, so node incidents are stored against this namespace
+// which is not a valid kubernetes namespace name
+const nodeIncidentNamespace = "_nodes"
+
+var nodeConditionGracePeriod time.Duration
+
+func init() {
+	viper.SetDefault("NODE_CONDITION_GRACE_PERIOD", "2m")
+	viper.AutomaticEnv()
+
+	nodeConditionGracePeriod = viper.GetDuration("NODE_CONDITION_GRACE_PERIOD")
+}
+
+// NodeReconciler reconciles a Node object
+type NodeReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	Store store.IncidentStore
+
+	logger logr.Logger
+}
+
+type nodeConditionResult struct {
+	conditionType corev1.NodeConditionType
+	summary       string
+	details       string
+	severity      models.Severity
+}
+
+//+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=nodes/status,verbs=get
+
+// Reconcile opens an incident for a node which is not ready or is under memory, disk or PID
+// pressure for longer than the grace period, and resolves it once the conditions clear.
+func (r *NodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = log.FromContext(ctx)
+
+	member := objectMember(string(models.NodeResource), req.Name)
+
+	instance := &corev1.Node{}
+	err := r.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			// the node is gone, so it can no longer be unhealthy
+			err = resolveActiveIncidentMember(ctx, r.Store, req.Name, nodeIncidentNamespace, member)
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	agentCreationTimestamp, err := r.Store.GetAgentCreationTimestamp(ctx)
+	if err != nil {
+		r.logger.Error(err, "incidentStore.GetAgentCreationTimestamp ERROR")
+		return ctrl.Result{}, err
+	}
+
+	results, requeueAfter := r.getUnhealthyConditions(instance, time.Unix(agentCreationTimestamp, 0))
+
+	if len(results) == 0 {
+		err = resolveActiveIncidentMember(ctx, r.Store, instance.Name, nodeIncidentNamespace, member)
+		if err != nil {
+			r.logger.Error(err, "error resolving node incident", "node", instance.Name)
+			return ctrl.Result{Requeue: true}, err
+		}
+
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	event := &models.PodEvent{
+		PodName:   member,
+		Namespace: nodeIncidentNamespace,
+		OwnerName: instance.Name,
+		OwnerType: string(models.NodeResource),
+		Timestamp: time.Now().Unix(),
+	}
+
+	for _, res := range results {
+		event.Severity = models.MaxSeverity(event.Severity, res.severity)
+	}
+
+	if len(results) == 1 {
+		event.Reason = results[0].summary
+		event.Message = results[0].details
+	} else {
+		for _, res := range results {
+			event.Reason += fmt.Sprintf("Condition: %s. Summary: %s\n", res.conditionType, res.summary)
+			event.Message += fmt.Sprintf("Condition: %s. Details: %s\n", res.conditionType, res.details)
+		}
+	}
+
+	added, err := addEventToActiveIncident(ctx, r.Client, r.Store, instance.Name, nodeIncidentNamespace, event)
+	if err != nil {
+		r.logger.Error(err, "error adding event to node incident", "node", instance.Name)
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	if added {
+		r.logger.Info("added node event to incident", "node", instance.Name, "reason", event.Reason)
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// getUnhealthyConditions returns the conditions of the node which have been unhealthy for
+// longer than the grace period, along with the time after which the node should be checked
+// again for conditions which are still within the grace period. Like pods created before the
+// agent, conditions which were already unhealthy when the agent was created are not reported.
+func (r *NodeReconciler) getUnhealthyConditions(
+	node *corev1.Node, agentCreatedAt time.Time,
+) ([]*nodeConditionResult, time.Duration) {
+	var results []*nodeConditionResult
+	var requeueAfter time.Duration
+
+	for _, condition := range node.Status.Conditions {
+		res := &nodeConditionResult{
+			conditionType: condition.Type,
+		}
+
+		switch condition.Type {
+		case corev1.NodeReady:
+			if condition.Status == corev1.ConditionTrue {
+				continue
+			}
+
+			res.summary = "The node is not ready"
+			res.severity = models.SeverityHigh
+			res.details = fmt.Sprintf("%s Pods running on this node may be unavailable "+
+				"and new pods will not be scheduled on it.", condition.Message)
+		case corev1.NodeMemoryPressure:
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+
+			res.summary = "The node is running low on memory"
+			res.severity = models.SeverityMedium
+			res.details = fmt.Sprintf("%s Pods running on this node may be evicted.", condition.Message)
+		case corev1.NodeDiskPressure:
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+
+			res.summary = "The node is running low on disk space"
+			res.severity = models.SeverityMedium
+			res.details = fmt.Sprintf("%s Pods running on this node may be evicted.", condition.Message)
+		case corev1.NodePIDPressure:
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+
+			res.summary = "The node is running low on process IDs"
+			res.severity = models.SeverityMedium
+			res.details = fmt.Sprintf("%s Pods running on this node may be evicted.", condition.Message)
+		default:
+			continue
+		}
+
+		if condition.LastTransitionTime.Time.Before(agentCreatedAt) {
+			continue
+		}
+
+		if remaining := time.Until(condition.LastTransitionTime.Add(nodeConditionGracePeriod)); remaining > 0 {
+			// the node may recover on its own, check again once the grace period is over
+			if requeueAfter == 0 || remaining < requeueAfter {
+				requeueAfter = remaining
+			}
+
+			continue
+		}
+
+		res.details = strings.TrimSpace(res.details)
+		results = append(results, res)
+	}
+
+	return results, requeueAfter
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NodeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Node{}).
+		Complete(r)
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetUnhealthyNodeConditions(t *testing.T) {
+	now := time.Now()
+	agentCreatedAt := now.Add(-time.Hour)
+
+	tests := []struct {
+		name                 string
+		condition            corev1.NodeCondition
+		expectedSummary      string
+		expectedRequeueAfter bool
+	}{
+		{
+			name: "memory pressure",
+			condition: corev1.NodeCondition{
+				Type:               corev1.NodeMemoryPressure,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(now.Add(-30 * time.Minute)),
+			},
+			expectedSummary: "The node is running low on memory",
+		},
+		{
+			name: "not ready",
+			condition: corev1.NodeCondition{
+				Type:               corev1.NodeReady,
+				Status:             corev1.ConditionUnknown,
+				LastTransitionTime: metav1.NewTime(now.Add(-30 * time.Minute)),
+			},
+			expectedSummary: "The node is not ready",
+		},
+		{
+			name: "within grace period",
+			condition: corev1.NodeCondition{
+				Type:               corev1.NodeDiskPressure,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(now),
+			},
+			expectedRequeueAfter: true,
+		},
+		{
+			name: "unhealthy before agent was created",
+			condition: corev1.NodeCondition{
+				Type:               corev1.NodeDiskPressure,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(agentCreatedAt.Add(-time.Minute)),
+			},
+		},
+		{
+			name: "healthy",
+			condition: corev1.NodeCondition{
+				Type:               corev1.NodePIDPressure,
+				Status:             corev1.ConditionFalse,
+				LastTransitionTime: metav1.NewTime(now.Add(-30 * time.Minute)),
+			},
+		},
+	}
+
+	r := &NodeReconciler{}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node := &corev1.Node{
+				Status: corev1.NodeStatus{
+					Conditions: []corev1.NodeCondition{test.condition},
+				},
+			}
+
+			results, requeueAfter := r.getUnhealthyConditions(node, agentCreatedAt)
+
+			if test.expectedSummary == "" {
+				if len(results) != 0 {
+					t.Errorf("expected no unhealthy conditions, got %q", results[0].summary)
+				}
+			} else if len(results) != 1 || results[0].summary != test.expectedSummary {
+				t.Errorf("expected unhealthy condition %q, got %v", test.expectedSummary, results)
+			}
+
+			if (requeueAfter > 0) != test.expectedRequeueAfter {
+				t.Errorf("expected requeue: %t, got requeue after %s", test.expectedRequeueAfter, requeueAfter)
+			}
+		})
+	}
+}
@@ -1,17 +1,17 @@
 package controllers
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/porter-dev/porter-agent/pkg/models"
-	"github.com/porter-dev/porter-agent/pkg/redis"
+	"github.com/porter-dev/porter-agent/pkg/policy"
+	"github.com/porter-dev/porter-agent/pkg/store"
 	"github.com/porter-dev/porter-agent/pkg/utils"
 	"github.com/spf13/viper"
 	appsv1 "k8s.io/api/apps/v1"
@@ -23,39 +23,22 @@ import (
 	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
-var (
-	redisHost        string
-	redisPort        string
-	maxTailLines     int64
-	containerSignals map[int32]string
-)
+var maxTailLines int64
 
 func init() {
-	viper.SetDefault("REDIS_HOST", "porter-redis-master")
-	viper.SetDefault("REDIS_PORT", "6379")
 	viper.SetDefault("MAX_TAIL_LINES", int64(100))
 	viper.AutomaticEnv()
 
-	redisHost = viper.GetString("REDIS_HOST")
-	redisPort = viper.GetString("REDIS_PORT")
 	maxTailLines = viper.GetInt64("MAX_TAIL_LINES")
-
-	// refer: https://www.man7.org/linux/man-pages/man7/signal.7.html
-	containerSignals = make(map[int32]string)
-	containerSignals[1] = "SIGHUP"
-	containerSignals[2] = "SIGINT"
-	containerSignals[3] = "SIGQUIT"
-	containerSignals[4] = "SIGILL"
-	containerSignals[5] = "SIGTRAP"
-	containerSignals[6] = "SIGABRT"
-	containerSignals[9] = "SIGKILL"
-	containerSignals[11] = "SIGSEGV"
-	containerSignals[15] = "SIGTERM"
 }
 
 const customFinalizer = "porter.run/agent-finalizer"
@@ -65,9 +48,10 @@ type PodReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 
-	redisClient *redis.Client
-	KubeClient  *kubernetes.Clientset
-	PodFilter   utils.PodFilter
+	Store           store.IncidentStore
+	KubeClient      *kubernetes.Clientset
+	PodFilter       utils.PodFilter
+	ReleaseResolver *utils.ReleaseResolver
 
 	logger logr.Logger
 }
@@ -75,6 +59,9 @@ type PodReconciler struct {
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=pods/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=core,resources=pods/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=secrets;configmaps,verbs=get
+//+kubebuilder:rbac:groups=apps,resources=statefulsets;daemonsets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=agent.porter.run,resources=incidentpolicies,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -88,10 +75,6 @@ type PodReconciler struct {
 func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	r.logger = log.FromContext(ctx)
 
-	if r.redisClient == nil {
-		r.redisClient = redis.NewClient(redisHost, redisPort, "", "", redis.PODSTORE, maxTailLines)
-	}
-
 	instance := &corev1.Pod{}
 	err := r.Get(ctx, req.NamespacedName, instance)
 	if err != nil {
@@ -105,16 +88,14 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return ctrl.Result{Requeue: true}, err
 	}
 
-	if instance.Namespace == "cert-manager" || instance.Namespace == "ingress-nginx" ||
-		instance.Namespace == "kube-node-lease" || instance.Namespace == "kube-public" ||
-		instance.Namespace == "kube-system" || instance.Namespace == "monitoring" ||
-		instance.Namespace == "porter-agent-system" {
+	// pods outside of the watched namespaces only get here to have the finalizer removed
+	if !isWatchedNamespace(r.Client, instance.Namespace) {
 		return ctrl.Result{}, nil
 	}
 
-	agentCreationTimestamp, err := r.redisClient.GetAgentCreationTimestamp(ctx)
+	agentCreationTimestamp, err := r.Store.GetAgentCreationTimestamp(ctx)
 	if err != nil {
-		r.logger.Error(err, "redisClient.GetAgentCreationTimestamp ERROR")
+		r.logger.Error(err, "incidentStore.GetAgentCreationTimestamp ERROR")
 		return ctrl.Result{}, err
 	}
 
@@ -124,47 +105,87 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 
 	porterReleaseName, ownerName, ownerKind, chartName := r.getOwnerDetails(ctx, req, instance)
 
-	// FIXME: we ignore the pod which has an empty release name, need to
-	//        change this behavior when making porter-agnostic
+	// we ignore the pod if none of the configured release strategies can identify its release
 	if porterReleaseName == "" {
 		return ctrl.Result{}, nil
 	}
 
 	if ownerKind == "Job" {
 		// we care only for the most recent pod for a job
-		jobPods, err := r.KubeClient.CoreV1().Pods(instance.Namespace).List(
-			ctx, metav1.ListOptions{
-				LabelSelector: fmt.Sprintf("app.kubernetes.io/instance=%s", porterReleaseName),
-			},
-		)
+		jobPods, err := r.ReleaseResolver.ListReleasePods(ctx, instance.Namespace, porterReleaseName)
 
 		if err != nil {
 			r.logger.Error(err, "error fetching list of job pods", "job", porterReleaseName, "pod", instance.Name)
 			return ctrl.Result{Requeue: true}, err
 		}
 
-		if len(jobPods.Items) > 0 {
-			sort.SliceStable(jobPods.Items, func(i, j int) bool {
-				return jobPods.Items[i].CreationTimestamp.After(jobPods.Items[j].CreationTimestamp.Time)
+		if len(jobPods) > 0 {
+			sort.SliceStable(jobPods, func(i, j int) bool {
+				return jobPods[i].CreationTimestamp.After(jobPods[j].CreationTimestamp.Time)
 			})
 
-			if jobPods.Items[0].Name != instance.Name {
+			if jobPods[0].Name != instance.Name {
 				return ctrl.Result{}, nil
 			}
 		}
 	}
 
+	incidentPolicy, err := policy.GetIncidentPolicy(ctx, r.Client, instance.Namespace, porterReleaseName)
+	if err != nil {
+		r.logger.Error(err, "error fetching incident policy", "release", porterReleaseName)
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	var restartHistory []*models.RestartSample
+
+	if ownerKind != "Job" {
+		// the restart history covers both the flapping window and the window of the restart threshold
+		window := flappingWindow
+
+		if incidentPolicy != nil && incidentPolicy.Spec.RestartThreshold != nil &&
+			incidentPolicy.Spec.RestartThreshold.Window.Duration > window {
+			window = incidentPolicy.Spec.RestartThreshold.Window.Duration
+		}
+
+		restartHistory, err = r.recordRestarts(ctx, instance, porterReleaseName, window)
+		if err != nil {
+			r.logger.Error(err, "error recording restarts", "release", porterReleaseName, "pod", instance.Name)
+			return ctrl.Result{Requeue: true}, err
+		}
+	}
+
+	flappingHistory := filterRestartHistory(restartHistory, time.Now().Add(-flappingWindow))
+
 	r.logger.Info("creating container events")
 
 	filteredMsgRes := r.PodFilter.Filter(instance, ownerKind == "Job")
+	flapping := false
+
+	// pods which crash every few minutes may be running whenever they are checked
+	if filteredMsgRes == nil && ownerKind != "Job" {
+		filteredMsgRes = getFlappingResult(instance, flappingHistory)
+		flapping = filteredMsgRes != nil
+	}
 
 	if filteredMsgRes == nil {
-		incidentID, err := r.redisClient.GetActiveIncident(ctx, porterReleaseName, instance.Namespace)
+		// pods which cannot be scheduled are only reported once their grace period is over
+		requeueAfter := utils.UnschedulableRequeueAfter(instance)
+
+		if utils.IsEvicted(instance) {
+			// an evicted pod is replaced by a new one, so it is resolved once its eviction no longer repeats
+			err = resolveActiveIncidentMember(ctx, r.Store, porterReleaseName, instance.Namespace, instance.Name)
+			if err != nil {
+				r.logger.Error(err, "error resolving evicted pod", "pod", instance.Name)
+				return ctrl.Result{Requeue: true}, err
+			}
+
+			return ctrl.Result{}, nil
+		}
+
+		incidentID, err := r.Store.GetActiveIncident(ctx, porterReleaseName, instance.Namespace)
 		if err == nil {
-			if ownerKind == "Job" {
-				// since a job has one running pod at a time and here we know that it has run successfully
-				r.redisClient.SetJobIncidentResolved(ctx, incidentID) // FIXME: make use of the error
-			} else {
+			// job incidents are resolved by the JobReconciler once a later run of the job completes
+			if ownerKind != "Job" {
 				allRunning := true
 
 				for _, container := range instance.Status.ContainerStatuses {
@@ -177,34 +198,55 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 				if allRunning {
 					startedAt, valid := r.getLatestRunningStartedAt(instance)
 					if valid && time.Now().After(startedAt.Add(10*time.Minute)) {
-						r.redisClient.SetPodResolved(ctx, instance.Name, incidentID) // FIXME: make use of the error
+						if err := r.Store.SetPodResolved(ctx, instance.Name, incidentID); err != nil {
+							r.logger.Error(err, "error resolving pod", "pod", instance.Name, "incidentID", incidentID)
+							return ctrl.Result{Requeue: true}, err
+						}
+
 						return ctrl.Result{}, nil
 					}
 				}
 
-				ignore, _ := r.canIgnoreMultipodDeployment(ctx, reconcile.Request{
+				ignore, _ := r.canIgnoreMultipodWorkload(ctx, reconcile.Request{
 					NamespacedName: types.NamespacedName{
 						Name:      ownerName,
 						Namespace: req.Namespace,
 					},
-				})
+				}, ownerKind, instance)
 
 				if ignore {
-					r.redisClient.SetJobIncidentResolved(ctx, incidentID)
+					if err := r.Store.SetJobIncidentResolved(ctx, incidentID); err != nil {
+						r.logger.Error(err, "error resolving incident", "incidentID", incidentID)
+						return ctrl.Result{Requeue: true}, err
+					}
 				}
 			}
 		}
 
-		return ctrl.Result{}, nil // FIXME: better introspection to requeue here
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil // FIXME: better introspection to requeue here
+	}
+
+	if ownerKind == "Job" && instance.Status.Phase != corev1.PodPending {
+		// failed runs of a job may be retried, so they are reported by the JobReconciler
+		// from the conditions of the job once it has run out of retries
+		return ctrl.Result{}, nil
+	}
+
+	if incidentPolicy != nil && incidentPolicy.Spec.Suppress {
+		r.logger.Info("suppressing incident by policy", "policy", incidentPolicy.Name, "pod", instance.Name)
+		return ctrl.Result{}, nil
 	}
 
 	containerEvents := make(map[string]*models.ContainerEvent)
 
 	for _, filteredContainerRes := range filteredMsgRes.ContainerStatuses {
 		containerEvents[filteredContainerRes.ContainerName] = &models.ContainerEvent{
-			Name:    filteredContainerRes.ContainerName,
-			Reason:  filteredContainerRes.Summary,
-			Message: filteredContainerRes.Details,
+			Name:          filteredContainerRes.ContainerName,
+			InitContainer: filteredContainerRes.IsInit,
+			Reason:        filteredContainerRes.Summary,
+			Message:       filteredContainerRes.Details,
+			ExitCode:      filteredContainerRes.ExitCode,
+			Signal:        filteredContainerRes.Signal,
 		}
 	}
 
@@ -213,37 +255,51 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	newIncident := false
 	incidentID := ""
 
-	exists, err := r.redisClient.ActiveIncidentExists(ctx, porterReleaseName, instance.Namespace)
+	exists, err := r.Store.ActiveIncidentExists(ctx, porterReleaseName, instance.Namespace)
 	if err != nil {
 		return ctrl.Result{Requeue: true}, err
 	}
 
 	if exists {
-		incidentID, err = r.redisClient.GetActiveIncident(ctx, porterReleaseName, instance.Namespace)
+		incidentID, err = r.Store.GetActiveIncident(ctx, porterReleaseName, instance.Namespace)
 		if err != nil {
 			return ctrl.Result{Requeue: true}, err
 		}
 	} else {
-		if ownerKind == "Deployment" {
-			ignore, _ := r.canIgnoreMultipodDeployment(ctx, reconcile.Request{
+		// repeated evictions are reported even when enough replicas are healthy
+		if ownerKind != "Job" && filteredMsgRes.Eviction == nil {
+			ignore, _ := r.canIgnoreMultipodWorkload(ctx, reconcile.Request{
 				NamespacedName: types.NamespacedName{
 					Name:      ownerName,
 					Namespace: req.Namespace,
 				},
-			})
+			}, ownerKind, instance)
 
 			if ignore {
-				r.logger.Info("ignoring multipod deployment", "deployment", ownerName, "pod", instance.Name)
+				r.logger.Info("ignoring multipod workload", "kind", ownerKind, "name", ownerName, "pod", instance.Name)
 				return ctrl.Result{}, nil
 			}
 		}
 
-		incidentID, err = r.redisClient.CreateActiveIncident(ctx, porterReleaseName, instance.Namespace)
+		// crashing pods are reported once they have restarted often enough, as soon as their next
+		// restart updates the pod
+		if incidentPolicy != nil && incidentPolicy.Spec.RestartThreshold != nil && isCrashing(instance, filteredMsgRes) {
+			threshold := incidentPolicy.Spec.RestartThreshold
+
+			since := time.Now().Add(-threshold.Window.Duration)
+
+			if restarts := countPodRestarts(restartHistory, instance.Name, since); restarts < threshold.Restarts {
+				r.logger.Info("restart threshold not reached", "policy", incidentPolicy.Name, "pod", instance.Name,
+					"restarts", restarts)
+				return ctrl.Result{}, nil
+			}
+		}
+
+		// another reconcile may have created the incident in the meantime
+		incidentID, newIncident, err = r.Store.GetOrCreateActiveIncident(ctx, porterReleaseName, instance.Namespace)
 		if err != nil {
 			return ctrl.Result{Requeue: true}, err
 		}
-
-		newIncident = true
 	}
 
 	r.logger.Info("active incident ID", "incidentID", incidentID)
@@ -259,16 +315,30 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		ContainerEvents: containerEvents,
 		Reason:          filteredMsgRes.PodSummary,
 		Message:         filteredMsgRes.PodDetails,
+		Severity:        r.getReplicaImpactSeverity(ctx, instance.Namespace, ownerKind, ownerName, filteredMsgRes.Severity),
+		Eviction:        filteredMsgRes.Eviction,
+	}
+
+	if flapping {
+		event.RestartHistory = getLatestRestarts(flappingHistory)
 	}
 
+	// suppressed releases were dropped before the incident was created
+	if _, err := applyIncidentPolicy(ctx, r.Client, porterReleaseName, instance.Namespace, event); err != nil {
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	// the stored events are redacted, so the event has to be redacted before it is compared to them
+	redactEvent(event)
+
 	r.logger.Info("checking for incident existence")
-	if exists, err := r.redisClient.IncidentExists(ctx, incidentID); err != nil {
+	if exists, err := r.Store.IncidentExists(ctx, incidentID); err != nil {
 		return ctrl.Result{Requeue: true}, err
 	} else if exists {
 		r.logger.Info("incident already exists")
 		// do not add duplicate events when possible
 		r.logger.Info("fetching latest event for incident")
-		latestEvent, err := r.redisClient.GetLatestEventForIncident(ctx, incidentID)
+		latestEvent, err := r.Store.GetLatestEventForIncident(ctx, incidentID)
 		if err != nil {
 			return ctrl.Result{Requeue: true}, err
 		} else if latestEvent != nil {
@@ -321,53 +391,23 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 
 	r.logger.Info("fetching logs for containers")
 	for containerName, containerEvent := range event.ContainerEvents {
-		logOptions := &corev1.PodLogOptions{
-			TailLines: &maxTailLines,
-			Previous:  r.hasLastTerminatedState(instance, containerName),
-			Container: containerName,
-		}
-
-		req := r.KubeClient.
-			CoreV1().
-			Pods(instance.Namespace).
-			GetLogs(instance.Name, logOptions)
-
-		podLogs, err := req.Stream(ctx)
-		if err != nil {
-			r.logger.Error(err, "error streaming logs")
-			return ctrl.Result{Requeue: true}, err
-		}
-		defer podLogs.Close()
-
-		logs := new(bytes.Buffer)
-		_, err = io.Copy(logs, podLogs)
+		strLogs, err := getContainerLogs(ctx, r.KubeClient, instance, containerName)
 		if err != nil {
 			r.logger.Error(err, "unable to read logs")
 			return ctrl.Result{Requeue: true}, err
 		}
 
-		strLogs := logs.String()
-
 		if strLogs != "" { // logs can be empty
 			if strings.Contains(strLogs, "unable to retrieve container logs") {
 				// let us not add this unhelpful log message and completely ignore this event
 				return ctrl.Result{}, nil
 			}
 
-			r.logger.Info("checking for duplicate logs", "incidentID", incidentID)
+			strLogs, containerEvent.LogRedactions = utils.RedactSecrets(strLogs)
 
-			duplicateLogs, err := r.redisClient.DuplicateLogs(ctx, incidentID, strLogs)
-			if err != nil {
-				r.logger.Error(err, "unable to check for duplicate logs")
-				return ctrl.Result{Requeue: true}, err
-			}
-
-			if duplicateLogs {
-				r.logger.Info("found duplicate logs", "incidentID", incidentID)
-				return ctrl.Result{}, nil
-			}
+			recordCrashSignature(containerEvent, strLogs)
 
-			logID, err := r.redisClient.AddLogs(ctx, incidentID, strLogs)
+			logID, err := r.Store.AddLogs(ctx, incidentID, strLogs)
 			if err != nil {
 				r.logger.Error(err, "error adding new logs")
 				return ctrl.Result{Requeue: true}, err
@@ -378,16 +418,17 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	}
 
 	r.logger.Info("adding event to incident")
-	err = r.redisClient.AddEventToIncident(ctx, incidentID, event, newIncident)
-	if err != nil && strings.Contains(err.Error(), "max event count") {
-		r.logger.Error(err, "max events reached for incident")
-		return ctrl.Result{}, nil
-	} else if err != nil {
+	added, err := addEventToIncident(ctx, r.Store, incidentID, event, newIncident)
+	if err != nil {
 		r.logger.Error(err, "error adding event to incident")
 		return ctrl.Result{Requeue: true}, err
+	} else if !added {
+		r.logger.Info("max events reached for incident", "incidentID", incidentID)
+		return ctrl.Result{}, nil
 	}
 
-	return ctrl.Result{}, nil
+	// evicted pods are checked again to be resolved once their eviction is over
+	return ctrl.Result{RequeueAfter: utils.EvictionRequeueAfter(instance)}, nil
 }
 
 // This method takes care of removing the agent finalizer from a pod, if it exists.
@@ -434,21 +475,6 @@ func (r *PodReconciler) getLatestRunningStartedAt(pod *corev1.Pod) (time.Time, b
 	return tm, count > 0
 }
 
-func (r *PodReconciler) hasLastTerminatedState(pod *corev1.Pod, containerName string) bool {
-	for i := len(pod.Status.ContainerStatuses) - 1; i >= 0; i-- {
-		if containerName == pod.Status.ContainerStatuses[i].Name {
-			if pod.Status.ContainerStatuses[i].LastTerminationState.Waiting != nil ||
-				pod.Status.ContainerStatuses[i].LastTerminationState.Terminated != nil {
-				return true
-			}
-
-			return false
-		}
-	}
-
-	return false
-}
-
 func (r *PodReconciler) fetchReplicaSetOwner(ctx context.Context, req ctrl.Request) (*metav1.OwnerReference, error) {
 	rs := &appsv1.ReplicaSet{}
 
@@ -478,11 +504,16 @@ func (r *PodReconciler) getOwnerDetails(ctx context.Context, req ctrl.Request, p
 		return "", "", "", ""
 	}
 
-	// in case of multiple owners, take the first
+	// in case of multiple owners, take the controller or else the first. Pods of deployments are
+	// owned by a replicaset while pods of statefulsets, daemonsets and jobs are owned directly.
 	var owner *metav1.OwnerReference
 	var err error
 
-	owner = &owners[0]
+	owner = metav1.GetControllerOf(pod)
+
+	if owner == nil {
+		owner = &owners[0]
+	}
 	chartName := ""
 
 	if owner.Kind == "ReplicaSet" {
@@ -506,33 +537,35 @@ func (r *PodReconciler) getOwnerDetails(ctx context.Context, req ctrl.Request, p
 			Name:      owner.Name,
 			Namespace: req.Namespace,
 		},
-	}, owner.Kind == "Job")
+	}, owner.Kind)
 
-	return pod.Labels["app.kubernetes.io/instance"], owner.Name, owner.Kind, chartName
+	return r.ReleaseResolver.GetReleaseName(ctx, pod), owner.Name, owner.Kind, chartName
 }
 
-func (r *PodReconciler) getOwnerChartName(ctx context.Context, req reconcile.Request, isJob bool) string {
-	if isJob {
-		job := &batchv1.Job{}
-
-		err := r.Client.Get(ctx, req.NamespacedName, job)
-		if err != nil {
-			r.logger.Error(err, "cannot fetch job object")
-			return ""
-		}
-
-		return job.Labels["helm.sh/chart"]
+func (r *PodReconciler) getOwnerChartName(ctx context.Context, req reconcile.Request, kind string) string {
+	var owner client.Object
+
+	switch kind {
+	case "Job":
+		owner = &batchv1.Job{}
+	case "StatefulSet":
+		owner = &appsv1.StatefulSet{}
+	case "DaemonSet":
+		owner = &appsv1.DaemonSet{}
+	case "Deployment":
+		owner = &appsv1.Deployment{}
+	default:
+		r.logger.Info("unsupported owner kind for chart name", "kind", kind)
+		return ""
 	}
 
-	depl := &appsv1.Deployment{}
-
-	err := r.Client.Get(ctx, req.NamespacedName, depl)
+	err := r.Client.Get(ctx, req.NamespacedName, owner)
 	if err != nil {
-		r.logger.Error(err, "cannot fetch deployment object")
+		r.logger.Error(err, "cannot fetch owner object", "kind", kind)
 		return ""
 	}
 
-	return depl.Labels["helm.sh/chart"]
+	return owner.GetLabels()["helm.sh/chart"]
 }
 
 func getMaxUnavailable(deployment *appsv1.Deployment) int32 {
@@ -552,6 +585,23 @@ func getMaxUnavailable(deployment *appsv1.Deployment) int32 {
 	return int32(unavailable)
 }
 
+// canIgnoreMultipodWorkload returns true if enough replicas of the workload owning the pod
+// are healthy for the failure of the pod to be ignored
+func (r *PodReconciler) canIgnoreMultipodWorkload(
+	ctx context.Context, req reconcile.Request, kind string, pod *corev1.Pod,
+) (bool, error) {
+	switch kind {
+	case "Deployment":
+		return r.canIgnoreMultipodDeployment(ctx, req)
+	case "StatefulSet":
+		return r.canIgnoreMultipodStatefulSet(ctx, req, pod)
+	case "DaemonSet":
+		return r.canIgnoreMultipodDaemonSet(ctx, req)
+	}
+
+	return false, nil
+}
+
 func (r *PodReconciler) canIgnoreMultipodDeployment(ctx context.Context, req reconcile.Request) (bool, error) {
 	depl := &appsv1.Deployment{}
 
@@ -570,9 +620,203 @@ func (r *PodReconciler) canIgnoreMultipodDeployment(ctx context.Context, req rec
 	return false, nil
 }
 
+func getStatefulSetMaxUnavailable(sts *appsv1.StatefulSet) int32 {
+	if sts.Spec.UpdateStrategy.Type != appsv1.RollingUpdateStatefulSetStrategyType {
+		return 0
+	}
+
+	// a statefulset updates a single pod at a time unless configured otherwise
+	maxUnavailable := intstrutil.FromInt(1)
+
+	if sts.Spec.UpdateStrategy.RollingUpdate != nil && sts.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable != nil {
+		maxUnavailable = *sts.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable
+	}
+
+	unavailable, err := intstrutil.GetScaledValueFromIntOrPercent(&maxUnavailable, int(getReplicas(sts.Spec.Replicas)), false)
+
+	if err != nil {
+		return 0
+	}
+
+	return int32(unavailable)
+}
+
+func getStatefulSetPartition(sts *appsv1.StatefulSet) int32 {
+	if sts.Spec.UpdateStrategy.RollingUpdate != nil && sts.Spec.UpdateStrategy.RollingUpdate.Partition != nil {
+		return *sts.Spec.UpdateStrategy.RollingUpdate.Partition
+	}
+
+	return 0
+}
+
+// statefulset pods are named "<statefulset_name>-<ordinal>"
+func getStatefulSetPodOrdinal(sts *appsv1.StatefulSet, pod *corev1.Pod) (int32, bool) {
+	ordinal, err := strconv.ParseInt(strings.TrimPrefix(pod.Name, sts.Name+"-"), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return int32(ordinal), true
+}
+
+func (r *PodReconciler) canIgnoreMultipodStatefulSet(ctx context.Context, req reconcile.Request, pod *corev1.Pod) (bool, error) {
+	sts := &appsv1.StatefulSet{}
+
+	err := r.Client.Get(ctx, req.NamespacedName, sts)
+	if err != nil {
+		r.logger.Error(err, "cannot fetch statefulset object")
+		return false, err
+	}
+
+	ordinal, ok := getStatefulSetPodOrdinal(sts, pod)
+	if !ok {
+		return false, nil
+	}
+
+	if sts.Spec.PodManagementPolicy != appsv1.ParallelPodManagement {
+		// with ordered pod management, a pod which is not ready blocks the pods after it from being
+		// created and the pods before it from being updated, so its failure can only be ignored when
+		// it is the last pod in that order
+		rollingOut := sts.Status.UpdateRevision != "" && sts.Status.CurrentRevision != sts.Status.UpdateRevision
+
+		if rollingOut && ordinal != getStatefulSetPartition(sts) {
+			return false, nil
+		} else if !rollingOut && ordinal != getReplicas(sts.Spec.Replicas)-1 {
+			return false, nil
+		}
+	}
+
+	minAvailable := getReplicas(sts.Spec.Replicas) - getStatefulSetMaxUnavailable(sts)
+
+	if minAvailable <= sts.Status.ReadyReplicas {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func getDaemonSetMaxUnavailable(ds *appsv1.DaemonSet) int32 {
+	if ds.Spec.UpdateStrategy.Type != appsv1.RollingUpdateDaemonSetStrategyType {
+		return 0
+	}
+
+	// a daemonset updates a single pod at a time unless configured otherwise
+	maxUnavailable := intstrutil.FromInt(1)
+
+	if ds.Spec.UpdateStrategy.RollingUpdate != nil && ds.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable != nil {
+		maxUnavailable = *ds.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable
+	}
+
+	unavailable, err := intstrutil.GetScaledValueFromIntOrPercent(&maxUnavailable, int(ds.Status.DesiredNumberScheduled), true)
+
+	if err != nil {
+		return 0
+	}
+
+	return int32(unavailable)
+}
+
+func (r *PodReconciler) canIgnoreMultipodDaemonSet(ctx context.Context, req reconcile.Request) (bool, error) {
+	ds := &appsv1.DaemonSet{}
+
+	err := r.Client.Get(ctx, req.NamespacedName, ds)
+	if err != nil {
+		r.logger.Error(err, "cannot fetch daemonset object")
+		return false, err
+	}
+
+	if ds.Status.NumberUnavailable <= getDaemonSetMaxUnavailable(ds) {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// getReplicaImpactSeverity raises the severity of a failure by the share of the replicas of the
+// workload which are unavailable: by one level if at least half of them are, and to critical if
+// none of them are available
+func (r *PodReconciler) getReplicaImpactSeverity(
+	ctx context.Context, namespace, kind, name string, severity models.Severity,
+) models.Severity {
+	var desired, available int32
+
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+
+	switch kind {
+	case "Deployment":
+		depl := &appsv1.Deployment{}
+
+		if err := r.Client.Get(ctx, key, depl); err != nil {
+			return severity
+		}
+
+		desired, available = getReplicas(depl.Spec.Replicas), depl.Status.AvailableReplicas
+	case "StatefulSet":
+		sts := &appsv1.StatefulSet{}
+
+		if err := r.Client.Get(ctx, key, sts); err != nil {
+			return severity
+		}
+
+		desired, available = getReplicas(sts.Spec.Replicas), sts.Status.AvailableReplicas
+	case "DaemonSet":
+		ds := &appsv1.DaemonSet{}
+
+		if err := r.Client.Get(ctx, key, ds); err != nil {
+			return severity
+		}
+
+		desired, available = ds.Status.DesiredNumberScheduled, ds.Status.NumberAvailable
+	default:
+		return severity
+	}
+
+	if desired == 0 {
+		return severity
+	} else if available == 0 {
+		return models.SeverityCritical
+	} else if 2*(desired-available) >= desired {
+		return severity.Escalate(1)
+	}
+
+	return severity
+}
+
+// isCrashing returns true if any of the containers of the result has exited, as opposed to
+// containers which could not be started at all
+func isCrashing(pod *corev1.Pod, filteredMsgRes *utils.FilteredMessageResult) bool {
+	crashing := make(map[string]bool)
+
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			crashing[status.Name] = status.State.Terminated != nil ||
+				(status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff")
+		}
+	}
+
+	for _, containerRes := range filteredMsgRes.ContainerStatuses {
+		if crashing[containerRes.ContainerName] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// agentFinalizerPredicate keeps the events of pods which still carry the finalizer of earlier
+// versions of the agent, so that it is removed from pods outside of the watched namespaces as well
+func agentFinalizerPredicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return controllerutil.ContainsFinalizer(obj, customFinalizer)
+	})
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *PodReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&corev1.Pod{}).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, enqueueNamespaceObjects(mgr.GetClient(), &corev1.PodList{}),
+			builder.WithPredicates(namespaceSelectedPredicate())).
+		WithEventFilter(predicate.Or(namespacePredicate(mgr.GetClient()), agentFinalizerPredicate())).
 		Complete(r)
 }
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/porter-dev/porter-agent/pkg/redis"
+	"github.com/porter-dev/porter-agent/pkg/store"
 	"github.com/porter-dev/porter-agent/pkg/utils"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -30,7 +30,8 @@ func getClientset() (*kubernetes.Clientset, error) {
 	return kubernetes.NewForConfig(config)
 }
 
-func ProcessDeletedPods() {
+// ProcessDeletedPods resolves the pods of active incidents which were deleted
+func ProcessDeletedPods(incidentStore store.IncidentStore) {
 	deletedPodsLogger.Info("Processing deleted pods")
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
@@ -43,9 +44,7 @@ func ProcessDeletedPods() {
 		return
 	}
 
-	redisClient := redis.NewClient(redisHost, redisPort, "", "", redis.PODSTORE, maxTailLines)
-
-	incidentIDs, err := redisClient.GetAllActiveIncidents(ctx)
+	incidentIDs, err := incidentStore.GetAllActiveIncidents(ctx)
 
 	if err != nil {
 		deletedPodsLogger.Error(err, "error getting active incidents")
@@ -55,7 +54,7 @@ func ProcessDeletedPods() {
 	deletedPodsLogger.Info(fmt.Sprintf("Found %d active incidents", len(incidentIDs)))
 
 	for _, id := range incidentIDs {
-		pods, err := redisClient.GetPodsForIncident(ctx, id)
+		pods, err := incidentStore.GetPodsForIncident(ctx, id)
 
 		if err != nil {
 			deletedPodsLogger.Error(err, "error getting pods for incident")
@@ -68,13 +67,18 @@ func ProcessDeletedPods() {
 		incidentObj, _ := utils.NewIncidentFromString(id)
 
 		for _, pod := range pods {
+			if isObjectMember(pod) {
+				// not a pod, resolved by the controller watching the object
+				continue
+			}
+
 			deletedPodsLogger.Info(fmt.Sprintf("Checking if pod %s is deleted", pod))
 
 			_, err := clientset.CoreV1().Pods(incidentObj.GetNamespace()).Get(ctx, pod, v1.GetOptions{})
 
 			if err != nil && errors.IsNotFound(err) {
 				// pod was deleted, so we should remove it from the incident
-				redisClient.SetPodResolved(ctx, pod, id)
+				incidentStore.SetPodResolved(ctx, pod, id)
 			} else if err != nil {
 				deletedPodsLogger.Info(fmt.Sprintf("Error getting pod %s: %v", pod, err))
 			}
@@ -22,9 +22,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	"github.com/gin-gonic/gin"
+	agentv1alpha1 "github.com/porter-dev/porter-agent/api/v1alpha1"
 	"github.com/porter-dev/porter-agent/controllers"
 	"github.com/porter-dev/porter-agent/pkg/consumer"
 	"github.com/porter-dev/porter-agent/pkg/server/routes"
+	"github.com/porter-dev/porter-agent/pkg/store"
 	"github.com/porter-dev/porter-agent/pkg/utils"
 	//+kubebuilder:scaffold:imports
 )
@@ -40,6 +42,8 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
+	utilruntime.Must(agentv1alpha1.AddToScheme(scheme))
+
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -47,11 +51,14 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var migrateFromRedis bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8000", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&migrateFromRedis, "migrate-from-redis", false,
+		"Copy the incidents stored in Redis into the bolt database at BOLT_PATH and exit.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -60,6 +67,16 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if migrateFromRedis {
+		if err := store.MigrateFromRedis(context.Background()); err != nil {
+			setupLog.Error(err, "unable to migrate incidents from redis")
+			os.Exit(1)
+		}
+
+		setupLog.Info("migrated incidents from redis")
+		os.Exit(0)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -75,7 +92,7 @@ func main() {
 
 	// first check if the redis server is running and wait for it if needed
 	kubeClient := kubernetes.NewForConfigOrDie(mgr.GetConfig())
-	for {
+	for store.GetBackend() == store.RedisBackend {
 		pods, err := kubeClient.CoreV1().Pods("porter-agent-system").List(
 			context.Background(), v1.ListOptions{
 				LabelSelector: "app.kubernetes.io/name=redis",
@@ -101,15 +118,76 @@ func main() {
 		time.Sleep(time.Second * 2)
 	}
 
+	// the incident store is shared by the controllers, the event consumer and the HTTP server
+	incidentStore, err := store.NewIncidentStore()
+	if err != nil {
+		setupLog.Error(err, "unable to create incident store", "backend", store.GetBackend())
+		os.Exit(1)
+	}
+
+	releaseResolver := utils.NewReleaseResolver(mgr.GetClient())
+
 	if err = (&controllers.PodReconciler{
-		Client:     mgr.GetClient(),
-		Scheme:     mgr.GetScheme(),
-		KubeClient: kubeClient,
-		PodFilter:  utils.NewAgentPodFilter(kubeClient),
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Store:           incidentStore,
+		KubeClient:      kubeClient,
+		PodFilter:       utils.NewAgentPodFilter(kubeClient, releaseResolver),
+		ReleaseResolver: releaseResolver,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Pod")
 		os.Exit(1)
 	}
+	if err = (&controllers.NodeReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Store:  incidentStore,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Node")
+		os.Exit(1)
+	}
+	if err = (&controllers.HPAReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Store:           incidentStore,
+		KubeClient:      kubeClient,
+		ReleaseResolver: releaseResolver,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HorizontalPodAutoscaler")
+		os.Exit(1)
+	}
+	if err = (&controllers.JobReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Store:           incidentStore,
+		KubeClient:      kubeClient,
+		PodFilter:       utils.NewAgentPodFilter(kubeClient, releaseResolver),
+		ReleaseResolver: releaseResolver,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Job")
+		os.Exit(1)
+	}
+	if err = (&controllers.CronJobReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Store:           incidentStore,
+		KubeClient:      kubeClient,
+		PodFilter:       utils.NewAgentPodFilter(kubeClient, releaseResolver),
+		ReleaseResolver: releaseResolver,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CronJob")
+		os.Exit(1)
+	}
+	if err = (&controllers.DeploymentReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Store:           incidentStore,
+		KubeClient:      kubeClient,
+		ReleaseResolver: releaseResolver,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Deployment")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -121,15 +199,21 @@ func main() {
 		os.Exit(1)
 	}
 
-	// create the event consumer
+	// create the event consumer, which reads incident policies straight from the API server since it
+	// starts consuming before the cache of the manager is synced
 	setupLog.Info("creating event consumer")
-	eventConsumer = consumer.NewEventConsumer(50, time.Millisecond, context.TODO())
+	eventConsumer = consumer.NewEventConsumer(50, time.Millisecond, context.TODO(), mgr.GetAPIReader(), incidentStore)
 
 	setupLog.Info("starting event consumer")
 	go eventConsumer.Start()
 
+	if store.ArchiveEnabled() {
+		setupLog.Info("starting incident archiver")
+		go store.NewArchiver(incidentStore).Start(context.Background())
+	}
+
 	setupLog.Info("starting HTTP server")
-	httpServer = routes.NewRouter()
+	httpServer = routes.NewRouter(incidentStore)
 	go httpServer.Run(":10001")
 
 	go func() {
@@ -138,7 +222,7 @@ func main() {
 		// was deleted, we set the pod's status to resolved
 		for {
 			time.Sleep(time.Minute * 5)
-			controllers.ProcessDeletedPods()
+			controllers.ProcessDeletedPods(incidentStore)
 		}
 	}()
 
@@ -9,38 +9,32 @@ import (
 	"context"
 
 	"github.com/go-logr/logr"
+	agentv1alpha1 "github.com/porter-dev/porter-agent/api/v1alpha1"
 	porterErrors "github.com/porter-dev/porter-agent/pkg/errors"
 	"github.com/porter-dev/porter-agent/pkg/httpclient"
+	"github.com/porter-dev/porter-agent/pkg/policy"
 	"github.com/porter-dev/porter-agent/pkg/pulsar"
-	"github.com/porter-dev/porter-agent/pkg/redis"
+	"github.com/porter-dev/porter-agent/pkg/store"
+	"github.com/porter-dev/porter-agent/pkg/utils"
 	"github.com/spf13/viper"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var (
-	redisHost    string
-	redisPort    string
-	maxTailLines int64
-	porterHost   string
-	porterPort   string
-	porterToken  string
-	clusterID    string
-	projectID    string
+	porterHost  string
+	porterPort  string
+	porterToken string
+	clusterID   string
+	projectID   string
 
 	consumerLog = ctrl.Log.WithName("event-consumer")
 )
 
 func init() {
-	viper.SetDefault("REDIS_HOST", "porter-redis-master")
-	viper.SetDefault("REDIS_PORT", "6379")
-	viper.SetDefault("MAX_TAIL_LINES", int64(100))
 	viper.SetDefault("PORTER_PORT", "80")
 	viper.AutomaticEnv()
 
-	redisHost = viper.GetString("REDIS_HOST")
-	redisPort = viper.GetString("REDIS_PORT")
-	maxTailLines = viper.GetInt64("MAX_TAIL_LINES")
-
 	porterPort = viper.GetString("PORTER_PORT")
 	porterHost = getStringOrDie("PORTER_HOST")
 	porterToken = getStringOrDie("PORTER_TOKEN")
@@ -50,8 +44,9 @@ func init() {
 }
 
 type EventConsumer struct {
-	redisClient *redis.Client
+	store       store.IncidentStore
 	httpClient  *httpclient.Client
+	kubeClient  client.Reader
 	pulsar      *pulsar.Pulsar
 	context     context.Context
 	consumerLog logr.Logger
@@ -69,10 +64,14 @@ func getStringOrDie(key string) string {
 	return value
 }
 
-func NewEventConsumer(timePeriod int, timeUnit time.Duration, ctx context.Context) *EventConsumer {
+func NewEventConsumer(
+	timePeriod int, timeUnit time.Duration, ctx context.Context, kubeClient client.Reader,
+	incidentStore store.IncidentStore,
+) *EventConsumer {
 	return &EventConsumer{
-		redisClient: redis.NewClient(redisHost, redisPort, "", "", redis.PODSTORE, maxTailLines),
+		store:       incidentStore,
 		httpClient:  httpclient.NewClient(fmt.Sprintf("%s:%s", porterHost, porterPort), porterToken),
+		kubeClient:  kubeClient,
 		pulsar:      pulsar.NewPulsar(timePeriod, timeUnit),
 		context:     ctx,
 		consumerLog: consumerLog,
@@ -82,7 +81,7 @@ func NewEventConsumer(timePeriod int, timeUnit time.Duration, ctx context.Contex
 func (e *EventConsumer) Start() {
 	e.consumerLog.Info("Starting event consumer")
 	for range e.pulsar.Pulsate() {
-		value, score, err := e.redisClient.GetItemFromPendingQueue(e.context)
+		value, score, err := e.store.GetItemFromPendingQueue(e.context)
 		if err != nil {
 			// log the error and continue
 			if !errors.Is(err, porterErrors.NoPendingItemError) {
@@ -102,6 +101,22 @@ func (e *EventConsumer) Start() {
 			incidentID = strings.TrimPrefix(payload, "resolved:")
 		}
 
+		incidentPolicy, err := e.getIncidentPolicy(incidentID)
+		if err != nil {
+			e.consumerLog.Error(err, "error fetching incident policy", "payload", payload)
+
+			if err := e.store.RequeueItemWithScore(e.context, value, score); err != nil {
+				e.consumerLog.Error(err, "error requeuing item in store with score", "payload", payload)
+			}
+
+			continue
+		}
+
+		if incidentPolicy != nil && incidentPolicy.Spec.Suppress {
+			e.consumerLog.Info("suppressing notification by policy", "payload", payload, "policy", incidentPolicy.Name)
+			continue
+		}
+
 		e.consumerLog.Info("doing HTTP post", "payload", payload)
 
 		if newIncident {
@@ -111,7 +126,7 @@ func (e *EventConsumer) Start() {
 
 				// requeue the object into the work queue
 				if !strings.Contains(err.Error(), "non-existent incident") {
-					err := e.redisClient.RequeueItemWithScore(e.context, value, score)
+					err := e.store.RequeueItemWithScore(e.context, value, score)
 					if err != nil {
 						// log error and continue
 						e.consumerLog.Error(err, "error requeuing item in store with score", "payload", payload)
@@ -126,7 +141,7 @@ func (e *EventConsumer) Start() {
 
 				if !strings.Contains(err.Error(), "non-existent incident") {
 					// requeue the object into the work queue
-					err := e.redisClient.RequeueItemWithScore(e.context, value, score)
+					err := e.store.RequeueItemWithScore(e.context, value, score)
 					if err != nil {
 						// log error and continue
 						e.consumerLog.Error(err, "error requeuing item in store with score", "payload", payload)
@@ -138,10 +153,20 @@ func (e *EventConsumer) Start() {
 	}
 }
 
+// getIncidentPolicy returns the incident policy of the release of the incident, if any
+func (e *EventConsumer) getIncidentPolicy(incidentID string) (*agentv1alpha1.IncidentPolicy, error) {
+	incidentObj, err := utils.NewIncidentFromString(incidentID)
+	if err != nil {
+		return nil, nil
+	}
+
+	return policy.GetIncidentPolicy(e.context, e.kubeClient, incidentObj.GetNamespace(), incidentObj.GetReleaseName())
+}
+
 func (e *EventConsumer) doHTTPPostNotifyNew(incidentID string) error {
 	e.consumerLog.Info("notify new", "incidentID", incidentID)
 
-	incident, err := e.redisClient.GetIncidentDetails(e.context, incidentID)
+	incident, err := e.store.GetIncidentDetails(e.context, incidentID)
 	if err != nil {
 		e.consumerLog.Error(err, "error sending http request for new incident")
 		return err
@@ -161,7 +186,7 @@ func (e *EventConsumer) doHTTPPostNotifyNew(incidentID string) error {
 func (e *EventConsumer) doHTTPPostNotifyResolved(incidentID string) error {
 	e.consumerLog.Info("notify resolved", "incidentID", incidentID)
 
-	incident, err := e.redisClient.GetIncidentDetails(e.context, incidentID)
+	incident, err := e.store.GetIncidentDetails(e.context, incidentID)
 	if err != nil {
 		e.consumerLog.Error(err, "error sending http request for new incident")
 		return err
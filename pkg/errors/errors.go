@@ -3,3 +3,9 @@ package errors
 import "errors"
 
 var NoPendingItemError = errors.New("no pending item")
+
+var MaxEventCountError = errors.New("reached max event count")
+
+// IncidentNotActiveError is returned when an event is added to an incident which was resolved in
+// the meantime, in which case the event belongs to a new incident
+var IncidentNotActiveError = errors.New("incident is no longer active")
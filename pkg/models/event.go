@@ -7,17 +7,63 @@ func (e *EventResourceType) String() string {
 }
 
 const (
-	PodResource EventResourceType = "Pod"
+	PodResource        EventResourceType = "Pod"
+	NodeResource       EventResourceType = "Node"
+	HPAResource        EventResourceType = "HorizontalPodAutoscaler"
+	JobResource        EventResourceType = "Job"
+	CronJobResource    EventResourceType = "CronJob"
+	DeploymentResource EventResourceType = "Deployment"
 )
 
 type EventCriticality string
 
 type ContainerEvent struct {
-	Name     string `json:"container_name"`
-	Reason   string `json:"reason"`
+	Name          string `json:"container_name"`
+	InitContainer bool   `json:"init_container"`
+	Reason        string `json:"reason"`
+	Message       string `json:"message"`
+	LogID         string `json:"log_id"`
+	ExitCode      int32  `json:"exit_code"`
+	Signal        string `json:"signal,omitempty"`
+
+	// LogRedactions is the number of secrets redacted from the logs, by the detector which found them
+	LogRedactions map[string]int `json:"log_redactions,omitempty"`
+
+	// CrashSignature is the exception or panic found in the logs, if any
+	CrashSignature *CrashSignature `json:"crash_signature,omitempty"`
+}
+
+// CrashSignature is the exception or panic which a container crashed with, extracted from its logs
+type CrashSignature struct {
+	// Language is one of "go", "python", "java", "kotlin", "node" or "ruby"
+	Language string `json:"language"`
+	Type     string `json:"type"`
+	Message  string `json:"message"`
+
+	// Frames are the innermost frames of the stack trace, innermost first
+	Frames []string `json:"frames,omitempty"`
+}
+
+type EvictionEvent struct {
 	Message  string `json:"message"`
-	LogID    string `json:"log_id"`
-	ExitCode int32  `json:"exit_code"`
+	NodeName string `json:"node_name"`
+	Resource string `json:"resource"`
+	Count    int    `json:"count"`
+}
+
+type RolloutEvent struct {
+	ReplicaSet  string   `json:"replica_set"`
+	Revision    string   `json:"revision"`
+	FailingPods []string `json:"failing_pods"`
+}
+
+// RestartSample records that a container restarted since the previous sample of its restart count
+type RestartSample struct {
+	PodName       string `json:"pod_name"`
+	ContainerName string `json:"container_name"`
+	RestartCount  int32  `json:"restart_count"`
+	Restarts      int32  `json:"restarts"`
+	Timestamp     int64  `json:"timestamp"`
 }
 
 type PodEvent struct {
@@ -33,5 +79,10 @@ type PodEvent struct {
 	Status          string                     `json:"pod_status"`
 	Reason          string                     `json:"reason"`
 	Message         string                     `json:"message"`
+	Severity        Severity                   `json:"severity"`
+	PolicySeverity  Severity                   `json:"policy_severity,omitempty"`
 	ContainerEvents map[string]*ContainerEvent `json:"container_events"`
+	Eviction        *EvictionEvent             `json:"eviction"`
+	Rollout         *RolloutEvent              `json:"rollout"`
+	RestartHistory  []*RestartSample           `json:"restart_history"`
 }
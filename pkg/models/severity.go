@@ -0,0 +1,68 @@
+package models
+
+// Severity is how urgently an incident or an event needs attention. The levels match the
+// severities of the IncidentPolicy resource.
+type Severity string
+
+const (
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+var severityLevels = []Severity{SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// ParseSeverity returns the severity with the given name, or false if there is none
+func ParseSeverity(name string) (Severity, bool) {
+	for _, severity := range severityLevels {
+		if string(severity) == name {
+			return severity, true
+		}
+	}
+
+	return "", false
+}
+
+// Level returns the position of the severity from low to critical. Events stored before
+// severities were introduced have no severity and are treated as medium.
+func (s Severity) Level() int {
+	for i, severity := range severityLevels {
+		if severity == s {
+			return i
+		}
+	}
+
+	return 1
+}
+
+// Escalate returns the severity raised by the given number of levels, up to critical
+func (s Severity) Escalate(levels int) Severity {
+	level := s.Level() + levels
+
+	if level >= len(severityLevels) {
+		level = len(severityLevels) - 1
+	} else if level < 0 {
+		level = 0
+	}
+
+	return severityLevels[level]
+}
+
+// AtLeast returns true if the severity is the same as or higher than the other severity
+func (s Severity) AtLeast(other Severity) bool {
+	return s.Level() >= other.Level()
+}
+
+// MaxSeverity returns the highest of the severities
+func MaxSeverity(severities ...Severity) Severity {
+	var res Severity
+
+	for _, severity := range severities {
+		if res == "" || severity.Level() > res.Level() {
+			res = severity
+		}
+	}
+
+	return res
+}
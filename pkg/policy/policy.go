@@ -0,0 +1,47 @@
+package policy
+
+import (
+	"context"
+	"sort"
+
+	agentv1alpha1 "github.com/porter-dev/porter-agent/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetIncidentPolicy returns the incident policy which applies to the release, or nil if there is
+// none. A policy which names the release takes precedence over a policy for the whole namespace,
+// and policies of the same kind are ordered by name.
+func GetIncidentPolicy(ctx context.Context, c client.Reader, namespace, releaseName string) (*agentv1alpha1.IncidentPolicy, error) {
+	policies := &agentv1alpha1.IncidentPolicyList{}
+
+	err := c.List(ctx, policies, client.InNamespace(namespace))
+	if meta.IsNoMatchError(err) {
+		// the incident policy CRD is not installed, in which case no policy applies
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(policies.Items, func(i, j int) bool {
+		return policies.Items[i].Name < policies.Items[j].Name
+	})
+
+	var namespacePolicy *agentv1alpha1.IncidentPolicy
+
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+
+		if !policy.AppliesTo(releaseName) {
+			continue
+		}
+
+		if len(policy.Spec.Releases) > 0 {
+			return policy, nil
+		} else if namespacePolicy == nil {
+			namespacePolicy = policy
+		}
+	}
+
+	return namespacePolicy, nil
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"sort"
 	"strconv"
 	"time"
 
@@ -19,6 +18,13 @@ const (
 	PODSTORE = iota
 )
 
+// the retention of incidents unless the client is configured otherwise
+const (
+	DefaultIncidentTTL       = time.Hour * 24 * 14
+	DefaultLogTTL            = time.Hour * 24 * 14
+	DefaultMaxIncidentEvents = 500
+)
+
 var agentCreationTimestamp int64 = 0
 
 // Client is a redis client that also holds the
@@ -26,6 +32,10 @@ var agentCreationTimestamp int64 = 0
 type Client struct {
 	client     *goredis.Client
 	maxEntries int64
+
+	incidentTTL       time.Duration
+	logTTL            time.Duration
+	maxIncidentEvents int64
 }
 
 func NewClient(host, port, username, password string, db int, maxEntries int64) *Client {
@@ -36,10 +46,21 @@ func NewClient(host, port, username, password string, db int, maxEntries int64)
 			Password: password,
 			DB:       db,
 		}),
-		maxEntries: maxEntries,
+		maxEntries:        maxEntries,
+		incidentTTL:       DefaultIncidentTTL,
+		logTTL:            DefaultLogTTL,
+		maxIncidentEvents: DefaultMaxIncidentEvents,
 	}
 }
 
+// SetRetention sets how long incidents and their logs are kept, and how many events are kept per
+// incident
+func (c *Client) SetRetention(incidentTTL, logTTL time.Duration, maxIncidentEvents int64) {
+	c.incidentTTL = incidentTTL
+	c.logTTL = logTTL
+	c.maxIncidentEvents = maxIncidentEvents
+}
+
 func (c *Client) AppendToNotifyWorkQueue(ctx context.Context, packed []byte) error {
 	key := "pending"
 
@@ -217,16 +238,9 @@ func (c *Client) GetLatestEventForIncident(ctx context.Context, incidentID strin
 }
 
 func (c *Client) AddEventToIncident(ctx context.Context, incidentID string, event *models.PodEvent, newIncident bool) error {
-	if !newIncident {
-		events, err := c.client.ZRange(ctx, incidentID, 0, -1).Result()
-		if err != nil {
-			return err
-		}
-
-		if len(events) >= 500 {
-			// FIXME: a better way to do this?
-			return fmt.Errorf("reached max event count of 500 for incident ID: %s", incidentID)
-		}
+	incidentObj, err := utils.NewIncidentFromString(incidentID)
+	if err != nil {
+		return err
 	}
 
 	score := time.Now().Unix()
@@ -238,163 +252,103 @@ func (c *Client) AddEventToIncident(ctx context.Context, incidentID string, even
 		return fmt.Errorf("error marshalling to JSON with event ID: %s. Error: %w", event.EventID, err)
 	}
 
-	_, err = c.client.ZAddArgs(ctx, incidentID, goredis.ZAddArgs{
-		Members: []goredis.Z{
-			{
-				Score:  float64(score),
-				Member: eventJSON,
-			},
-		},
-	}).Result()
-	if err != nil {
-		return fmt.Errorf("error adding new pod event to incident with ID: %s. Error: %w", incidentID, err)
-	}
-
-	incidentObj, _ := utils.NewIncidentFromString(incidentID)
-
+	newIncidentArg := "0"
 	if newIncident {
-		// set a TTL for 2 weeks
-		_, err = c.client.ExpireAt(ctx, incidentID, incidentObj.GetTimestampAsTime().Add(time.Hour*24*14)).Result()
-		if err != nil {
-			return fmt.Errorf("error setting expiration to incident with ID: %s. Error: %w", incidentID, err)
-		}
+		newIncidentArg = "1"
 	}
 
-	_, err = c.client.SAdd(ctx, fmt.Sprintf("pods:%s", incidentID), event.PodName).Result()
-	if err != nil {
-		return fmt.Errorf("error adding new pod: %s to pod set with incident ID: %s. Error: %w",
-			event.PodName, incidentID, err)
-	}
+	severity := utils.GetEventSeverity(event)
 
-	if newIncident {
-		_, err = c.client.ExpireAt(ctx, fmt.Sprintf("pods:%s", incidentID),
-			incidentObj.GetTimestampAsTime().Add(time.Hour*24*14)).Result()
+	if !newIncident {
+		// incidents stored before their severity was kept get it from their events
+		prevSeverity, _, err := c.GetIncidentSeverity(ctx, incidentID)
 		if err != nil {
-			return fmt.Errorf("error setting expiration for pod set for incident ID: %s. Error: %w", incidentID, err)
+			return err
 		}
 
-		// we need to add this new incident to the pending queue so that it gets pushed out as a notification
-		c.AppendToNotifyWorkQueue(ctx, []byte("new:"+incidentID))
+		severity = models.MaxSeverity(severity, prevSeverity)
 	}
 
-	return nil
-}
-
-func (c *Client) SetPodResolved(ctx context.Context, podName, incidentID string) error {
-	if exists, err := c.IncidentExists(ctx, incidentID); err != nil {
-		return err
-	} else if !exists {
-		return fmt.Errorf("trying to set pod resolved for non-existent incident with ID: %s", incidentID)
-	}
-
-	key := fmt.Sprintf("pods:%s", incidentID)
-
-	_, err := c.client.SRem(ctx, key, podName).Result()
+	added, err := addEventToIncidentScript.Run(ctx, c.client,
+		[]string{
+			incidentID,
+			fmt.Sprintf("pods:%s", incidentID),
+			incidentsIndexKey,
+			releaseIncidentsIndexKey(incidentObj.GetReleaseName(), incidentObj.GetNamespace()),
+			"pending",
+			fmt.Sprintf("active_incident:%s:%s", incidentObj.GetReleaseName(), incidentObj.GetNamespace()),
+			fmt.Sprintf("severity:%s", incidentID),
+		},
+		newIncidentArg, c.maxIncidentEvents, score, eventJSON, event.PodName,
+		incidentObj.GetTimestampAsTime().Add(c.incidentTTL).Unix(), incidentObj.GetTimestamp(),
+		string(severity), severity.Level(), string(event.PolicySeverity),
+	).Int()
 	if err != nil {
-		return fmt.Errorf("error trying to set pod resolved for pod: %s for incident ID: %s", podName, incidentID)
+		return fmt.Errorf("error adding new pod event to incident with ID: %s. Error: %w", incidentID, err)
 	}
 
-	if affectedPods, err := c.client.SMembers(ctx, key).Result(); err != nil {
-		return fmt.Errorf("error trying to get members of set: %s. Error: %w", key, err)
-	} else if len(affectedPods) == 0 {
-		// all pods are now healthy, delete the active incident
-
-		incidentObj, _ := utils.NewIncidentFromString(incidentID)
-
-		_, err = c.client.Del(ctx, key).Result()
-		if err != nil {
-			return fmt.Errorf("error trying to remove pods for resolved incident ID: %s. Error: %w", incidentID, err)
-		}
-
-		_, err = c.client.Del(ctx, fmt.Sprintf("active_incident:%s:%s", incidentObj.GetReleaseName(),
-			incidentObj.GetNamespace())).Result()
-		if err != nil {
-			return fmt.Errorf("error trying to remove %s from active_incident. Error: %w", incidentID, err)
-		}
-
-		err = c.AppendToNotifyWorkQueue(ctx, []byte("resolved:"+incidentID))
-		if err != nil {
-			return fmt.Errorf("error adding resolved incident to work queue with ID: %s. Error: %w", incidentID, err)
-		}
+	if added == -1 {
+		return fmt.Errorf("%w: incident ID: %s", porterErrors.IncidentNotActiveError, incidentID)
+	} else if added == 0 {
+		return fmt.Errorf("%w of %d for incident ID: %s", porterErrors.MaxEventCountError, c.maxIncidentEvents, incidentID)
 	}
 
 	return nil
 }
 
-func (c *Client) SetJobIncidentResolved(ctx context.Context, incidentID string) error {
-	if exists, err := c.IncidentExists(ctx, incidentID); err != nil {
-		return err
-	} else if !exists {
-		return fmt.Errorf("trying to set job incident resolved for non-existent incident with ID: %s", incidentID)
-	}
-
-	incidentObj, _ := utils.NewIncidentFromString(incidentID)
-
-	_, err := c.client.Del(ctx, fmt.Sprintf("pods:%s", incidentID)).Result()
+func (c *Client) SetPodResolved(ctx context.Context, podName, incidentID string) error {
+	incidentObj, err := utils.NewIncidentFromString(incidentID)
 	if err != nil {
-		return fmt.Errorf("error trying to remove pods for resolved job incident ID: %s. Error: %w", incidentID, err)
+		return err
 	}
 
-	_, err = c.client.Del(ctx, fmt.Sprintf("active_incident:%s:%s", incidentObj.GetReleaseName(),
-		incidentObj.GetNamespace())).Result()
+	res, err := setPodResolvedScript.Run(ctx, c.client,
+		[]string{
+			incidentID,
+			fmt.Sprintf("pods:%s", incidentID),
+			fmt.Sprintf("active_incident:%s:%s", incidentObj.GetReleaseName(), incidentObj.GetNamespace()),
+			activeIncidentsIndexKey,
+			"pending",
+		},
+		podName, time.Now().Unix(),
+	).Int()
 	if err != nil {
-		return fmt.Errorf("error trying to remove %s from active_incident. Error: %w", incidentID, err)
+		return fmt.Errorf("error trying to set pod resolved for pod: %s for incident ID: %s. Error: %w",
+			podName, incidentID, err)
 	}
 
-	err = c.AppendToNotifyWorkQueue(ctx, []byte("resolved:"+incidentID))
-	if err != nil {
-		return fmt.Errorf("error adding resolved incident to work queue with ID: %s. Error: %w", incidentID, err)
+	if res == -1 {
+		return fmt.Errorf("trying to set pod resolved for non-existent incident with ID: %s", incidentID)
 	}
 
 	return nil
 }
 
-func (c *Client) GetIncidentDetails(ctx context.Context, incidentID string) (*models.Incident, error) {
-	if exists, err := c.IncidentExists(ctx, incidentID); err != nil {
-		return nil, err
-	} else if !exists {
-		return nil, fmt.Errorf("trying to get details of non-existent incident with ID: %s", incidentID)
-	}
-
+func (c *Client) SetJobIncidentResolved(ctx context.Context, incidentID string) error {
 	incidentObj, err := utils.NewIncidentFromString(incidentID)
 	if err != nil {
-		return nil, fmt.Errorf("error getting incident object for incident ID: %s. Error: %w", incidentID, err)
-	}
-
-	incident := &models.Incident{
-		ID:          incidentID,
-		ReleaseName: incidentObj.GetReleaseName(),
-		CreatedAt:   incidentObj.GetTimestamp(),
-	}
-
-	resolved, err := c.IsIncidentResolved(ctx, incidentID)
-	if err != nil {
-		return nil, fmt.Errorf("error checking if incident is resolved with incidentID: %s. Error: %w", incidentID, err)
-	}
-
-	if resolved {
-		incident.LatestState = "RESOLVED"
-	} else {
-		incident.LatestState = "ONGOING"
+		return err
 	}
 
-	latestEvent, err := c.GetLatestEventForIncident(ctx, incidentID)
+	res, err := setJobIncidentResolvedScript.Run(ctx, c.client,
+		[]string{
+			incidentID,
+			fmt.Sprintf("pods:%s", incidentID),
+			fmt.Sprintf("active_incident:%s:%s", incidentObj.GetReleaseName(), incidentObj.GetNamespace()),
+			activeIncidentsIndexKey,
+			"pending",
+		},
+		time.Now().Unix(),
+	).Int()
 	if err != nil {
-		return nil, fmt.Errorf("error fetching latest event with incidentID: %s. Error: %w", incidentID, err)
+		return fmt.Errorf("error trying to set job incident resolved for incident ID: %s. Error: %w", incidentID, err)
 	}
 
-	incident.ChartName = latestEvent.ChartName
-	incident.UpdatedAt = latestEvent.Timestamp
-
-	if incident.LatestState == "RESOLVED" {
-		incident.LatestReason = "Resolved"
-		incident.LatestMessage = "This incident has been resolved"
-	} else {
-		incident.LatestReason = latestEvent.Reason
-		incident.LatestMessage = latestEvent.Message
+	if res == -1 {
+		return fmt.Errorf("trying to set job incident resolved for non-existent incident with ID: %s", incidentID)
 	}
 
-	return incident, nil
+	return nil
 }
 
 func (c *Client) IsIncidentResolved(ctx context.Context, incidentID string) (bool, error) {
@@ -411,33 +365,31 @@ func (c *Client) IsIncidentResolved(ctx context.Context, incidentID string) (boo
 }
 
 func (c *Client) GetAllIncidents(ctx context.Context) ([]string, error) {
-	incidents, err := c.client.Keys(ctx, "incident:*:*:*").Result()
-	if err != nil {
-		return nil, err
-	}
-
-	sort.SliceStable(incidents, func(i, j int) bool {
-		objA, _ := utils.NewIncidentFromString(incidents[i])
-		objB, _ := utils.NewIncidentFromString(incidents[j])
-
-		return objA.GetTimestamp() > objB.GetTimestamp()
-	})
-
-	return incidents, nil
+	return c.getIndexedIncidents(ctx, incidentsIndexKey)
 }
 
 func (c *Client) GetAllActiveIncidents(ctx context.Context) ([]string, error) {
-	incidents, err := c.client.Keys(ctx, "active_incident:*:*").Result()
+	incidents, err := c.getIndexedIncidents(ctx, activeIncidentsIndexKey)
 	if err != nil {
 		return nil, err
 	}
 
 	var incidentIDs []string
 
-	for _, incident := range incidents {
-		incidentID, err := c.client.Get(ctx, incident).Result()
-		if err == nil {
+	for _, incidentID := range incidents {
+		incidentObj, err := utils.NewIncidentFromString(incidentID)
+		if err != nil {
+			continue
+		}
+
+		// the index may still hold an incident whose active incident key has expired
+		activeID, err := c.GetActiveIncident(ctx, incidentObj.GetReleaseName(), incidentObj.GetNamespace())
+		if err == nil && activeID == incidentID {
 			incidentIDs = append(incidentIDs, incidentID)
+		} else if errors.Is(err, goredis.Nil) || (err == nil && activeID != incidentID) {
+			if err := c.unindexActiveIncident(ctx, incidentID); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -454,19 +406,7 @@ func (c *Client) GetPodsForIncident(ctx context.Context, incidentID string) ([]s
 }
 
 func (c *Client) GetIncidentsByReleaseNamespace(ctx context.Context, releaseName, namespace string) ([]string, error) {
-	incidents, err := c.client.Keys(ctx, fmt.Sprintf("incident:%s:%s:*", releaseName, namespace)).Result()
-	if err != nil {
-		return nil, err
-	}
-
-	sort.SliceStable(incidents, func(i, j int) bool {
-		objA, _ := utils.NewIncidentFromString(incidents[i])
-		objB, _ := utils.NewIncidentFromString(incidents[j])
-
-		return objA.GetTimestamp() > objB.GetTimestamp()
-	})
-
-	return incidents, nil
+	return c.getIndexedIncidents(ctx, releaseIncidentsIndexKey(releaseName, namespace))
 }
 
 func (c *Client) GetIncidentEventsByID(ctx context.Context, incidentID string) ([]*models.PodEvent, error) {
@@ -504,106 +444,72 @@ func (c *Client) GetIncidentEventsByID(ctx context.Context, incidentID string) (
 	return events, nil
 }
 
-func (c *Client) AddLogs(ctx context.Context, incidentID, strLogs string) (string, error) {
-	score := time.Now().Unix()
-
-	logID := fmt.Sprintf("log:%s:%d", incidentID, score)
-
-	if _, err := c.client.Set(ctx, logID, strLogs, time.Hour*24*14).Result(); err != nil {
-		return "", errors.New("error adding logs")
+func (c *Client) GetIncidentSeverity(ctx context.Context, incidentID string) (models.Severity, models.Severity, error) {
+	fields, err := c.client.HGetAll(ctx, fmt.Sprintf("severity:%s", incidentID)).Result()
+	if err != nil {
+		return "", "", fmt.Errorf("error getting severity for incident ID: %s. Error: %w", incidentID, err)
 	}
 
-	logsID := fmt.Sprintf("logs:%s", incidentID)
-
-	if _, err := c.client.ZAddArgs(ctx, logsID, goredis.ZAddArgs{
-		Members: []goredis.Z{
-			{
-				Score:  float64(score),
-				Member: logID,
-			},
-		},
-	}).Result(); err != nil {
-		return "", fmt.Errorf("error adding new log with ID: %s to logs set of incident ID: %s. Error: %w",
-			logID, incidentID, err)
+	if severity, ok := fields["severity"]; ok {
+		return models.Severity(severity), models.Severity(fields["policy_severity"]), nil
 	}
 
-	if exists, err := c.client.Exists(ctx, logsID).Result(); err != nil {
-		return "", fmt.Errorf("error checking existence of logs set for incident ID: %s. Error: %w",
-			incidentID, err)
-	} else if exists == 0 {
-		incidentObj, err := utils.NewIncidentFromString(incidentID)
-		if err != nil {
-			return "", fmt.Errorf("error converting incident from string to object while creating new logs set for incident ID: %s. Error: %w",
-				incidentID, err)
-		}
-
-		if _, err := c.client.ExpireAt(ctx, logsID, incidentObj.GetTimestampAsTime().Add(time.Hour*24*14)).Result(); err != nil {
-			return "", fmt.Errorf("error setting expiration time for logs set for incident ID: %s. Error: %w",
-				incidentID, err)
-		}
+	// incidents stored before their severity was kept get it from their events
+	events, err := c.GetIncidentEventsByID(ctx, incidentID)
+	if err != nil {
+		return "", "", fmt.Errorf("error fetching events with incidentID: %s. Error: %w", incidentID, err)
 	}
 
-	return logID, nil
-}
+	severity, policySeverity := utils.GetEventsSeverity(events)
 
-func (c *Client) DuplicateLogs(ctx context.Context, incidentID, strLogs string) (bool, error) {
-	logsID := fmt.Sprintf("logs:%s", incidentID)
+	return severity, policySeverity, nil
+}
 
-	// check if any logs exist for this incident
-	if exists, err := c.client.Exists(ctx, logsID).Result(); err != nil {
-		return false, fmt.Errorf("error checking for logs set existence for incident ID: %s while checking for duplicate logs. Error: %w",
+func (c *Client) AddLogs(ctx context.Context, incidentID, strLogs string) (string, error) {
+	incidentObj, err := utils.NewIncidentFromString(incidentID)
+	if err != nil {
+		return "", fmt.Errorf("error converting incident from string to object while creating new logs set for incident ID: %s. Error: %w",
 			incidentID, err)
-	} else if exists == 0 {
-		return false, nil
 	}
 
-	previousLogID, err := c.client.ZRangeArgsWithScores(ctx, goredis.ZRangeArgs{
-		Key:   logsID,
-		Start: 0,
-		Stop:  1,
-		Rev:   true,
-	}).Result()
+	compressed, err := utils.CompressLogs(strLogs)
 	if err != nil {
-		return false, fmt.Errorf("error getting log IDs from logs set for incident ID: %s. Error: %w",
-			incidentID, err)
+		return "", err
 	}
 
-	if len(previousLogID) == 0 {
-		// FIXME: do we need to check for existence before in this case?
-		return false, nil
-	}
+	logID := utils.NewLogID(strLogs)
+	logsID := fmt.Sprintf("logs:%s", incidentID)
 
-	logID, ok := previousLogID[0].Member.(string)
-	if !ok {
-		return false, fmt.Errorf("error converting logs set member to string for incident ID: %s", incidentID)
-	}
+	now := time.Now()
+	incidentExpiresAt := incidentObj.GetTimestampAsTime().Add(c.incidentTTL)
 
-	log, err := c.client.Get(ctx, logID).Result()
-	if err != nil {
-		return false, fmt.Errorf("error getting logs with ID: %s while checking for duplicate logs. Error: %w",
-			logID, err)
+	// logs are kept for their retention, but never expire before the incidents which refer to them
+	expiresAt := now.Add(c.logTTL)
+
+	if incidentExpiresAt.After(expiresAt) {
+		expiresAt = incidentExpiresAt
 	}
 
-	if log == strLogs {
-		return true, nil
+	err = addLogsScript.Run(ctx, c.client, []string{logID, logsID},
+		compressed, expiresAt.Unix(), now.Unix(), incidentExpiresAt.Unix(),
+	).Err()
+	if err != nil {
+		return "", fmt.Errorf("error adding new log with ID: %s to logs set of incident ID: %s. Error: %w",
+			logID, incidentID, err)
 	}
 
-	return false, nil
+	return logID, nil
 }
 
 func (c *Client) GetLogs(ctx context.Context, logID string) (string, error) {
-	if exists, err := c.client.Exists(ctx, logID).Result(); err != nil {
-		return "", fmt.Errorf("error fetching logs with ID: %s", logID)
-	} else if exists == 0 {
+	logs, err := c.client.Get(ctx, logID).Bytes()
+	if err == goredis.Nil {
 		return "", fmt.Errorf("no such logs with ID: %s", logID)
-	}
-
-	logs, err := c.client.Get(ctx, logID).Result()
-	if err != nil {
+	} else if err != nil {
 		return "", fmt.Errorf("error fetching logs with ID: %s. Error: %w", logID, err)
 	}
 
-	return logs, nil
+	return utils.DecompressLogs(logs)
 }
 
 func (c *Client) GetActiveIncident(ctx context.Context, releaseName, namespace string) (string, error) {
@@ -634,16 +540,183 @@ func (c *Client) ActiveIncidentExists(ctx context.Context, releaseName, namespac
 	return true, nil
 }
 
-func (c *Client) CreateActiveIncident(ctx context.Context, releaseName, namespace string) (string, error) {
+// GetOrCreateActiveIncident returns the ID of the active incident of the release, creating a new
+// incident if none is active, and whether the incident is new
+func (c *Client) GetOrCreateActiveIncident(ctx context.Context, releaseName, namespace string) (string, bool, error) {
 	key := fmt.Sprintf("active_incident:%s:%s", releaseName, namespace)
 
 	newIncident := utils.NewIncident(releaseName, namespace, time.Now().Unix())
 
-	_, err := c.client.Set(ctx, key, newIncident.ToString(), time.Hour*24*14).Result()
+	res, err := getOrCreateActiveIncidentScript.Run(ctx, c.client,
+		[]string{key, activeIncidentsIndexKey},
+		newIncident.ToString(), int64(c.incidentTTL.Seconds()), newIncident.GetTimestamp(),
+	).Slice()
 	if err != nil {
-		return "", fmt.Errorf("error creating new active incident for release %s with namespace %s. Error: %w",
+		return "", false, fmt.Errorf("error creating new active incident for release %s with namespace %s. Error: %w",
 			releaseName, namespace, err)
 	}
 
-	return newIncident.ToString(), nil
+	if len(res) != 2 {
+		return "", false, fmt.Errorf("unexpected result creating new active incident for release %s with namespace %s",
+			releaseName, namespace)
+	}
+
+	incidentID, _ := res[0].(string)
+	created, _ := res[1].(int64)
+
+	return incidentID, created == 1, nil
+}
+
+// GetLastRestartCount returns the restart count of a container of the release which was recorded
+// last, if any
+func (c *Client) GetLastRestartCount(
+	ctx context.Context, releaseName, namespace, podName, containerName string,
+) (int32, bool, error) {
+	key := fmt.Sprintf("restart_counts:%s:%s", releaseName, namespace)
+
+	value, err := c.client.HGet(ctx, key, fmt.Sprintf("%s/%s", podName, containerName)).Result()
+	if err == goredis.Nil {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, fmt.Errorf("error fetching restart count of container %s of pod %s. Error: %w",
+			containerName, podName, err)
+	}
+
+	count, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return int32(count), true, nil
+}
+
+// AddRestartSample records the restart count of a container of the release, and adds the sample
+// to the restart history of the release if the container restarted. Samples older than the
+// window are dropped from the history.
+func (c *Client) AddRestartSample(
+	ctx context.Context, releaseName, namespace string, sample *models.RestartSample, window time.Duration,
+) error {
+	countsKey := fmt.Sprintf("restart_counts:%s:%s", releaseName, namespace)
+	historyKey := fmt.Sprintf("restart_history:%s:%s", releaseName, namespace)
+
+	sampleJSON, err := json.Marshal(sample)
+	if err != nil {
+		return fmt.Errorf("error marshalling restart sample to JSON for pod %s. Error: %w", sample.PodName, err)
+	}
+
+	// the count and the history are updated in a single transaction
+	_, err = c.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.HSet(ctx, countsKey, fmt.Sprintf("%s/%s", sample.PodName, sample.ContainerName), sample.RestartCount)
+
+		// the counts of pods which are gone expire along with the history, as long as the release is
+		// not restarting anymore
+		pipe.Expire(ctx, countsKey, window)
+
+		if sample.Restarts <= 0 {
+			return nil
+		}
+
+		pipe.ZAdd(ctx, historyKey, &goredis.Z{
+			Score:  float64(sample.Timestamp),
+			Member: sampleJSON,
+		})
+		pipe.ZRemRangeByScore(ctx, historyKey, "-inf", fmt.Sprintf("(%d", time.Now().Add(-window).Unix()))
+		pipe.Expire(ctx, historyKey, window)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error recording restart sample of container %s of pod %s for release %s in namespace %s. Error: %w",
+			sample.ContainerName, sample.PodName, releaseName, namespace, err)
+	}
+
+	return nil
+}
+
+// GetRestartHistory returns the restart samples of the release since the given time, oldest first
+func (c *Client) GetRestartHistory(
+	ctx context.Context, releaseName, namespace string, since time.Time,
+) ([]*models.RestartSample, error) {
+	key := fmt.Sprintf("restart_history:%s:%s", releaseName, namespace)
+
+	members, err := c.client.ZRangeByScore(ctx, key, &goredis.ZRangeBy{
+		Min: strconv.FormatInt(since.Unix(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching restart history for release %s in namespace %s. Error: %w",
+			releaseName, namespace, err)
+	}
+
+	var history []*models.RestartSample
+
+	for _, member := range members {
+		sample := &models.RestartSample{}
+
+		if err := json.Unmarshal([]byte(member), sample); err != nil {
+			return nil, fmt.Errorf("error unmarshalling restart sample for release %s in namespace %s. Error: %w",
+				releaseName, namespace, err)
+		}
+
+		history = append(history, sample)
+	}
+
+	return history, nil
+}
+
+// PendingItem is an item of the queue of pending notifications along with its score
+type PendingItem struct {
+	Packed []byte
+	Score  float64
+}
+
+// GetPendingItems returns the items of the queue of pending notifications without removing them
+func (c *Client) GetPendingItems(ctx context.Context) ([]*PendingItem, error) {
+	members, err := c.client.ZRangeWithScores(ctx, "pending", 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching pending items. Error: %w", err)
+	}
+
+	var items []*PendingItem
+
+	for _, member := range members {
+		packed, ok := member.Member.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot caste item to bytearray, actual type: %T", member.Member)
+		}
+
+		items = append(items, &PendingItem{
+			Packed: []byte(packed),
+			Score:  member.Score,
+		})
+	}
+
+	return items, nil
+}
+
+// IncidentLogID is the ID of logs of an incident along with the time they were added
+type IncidentLogID struct {
+	LogID     string
+	Timestamp int64
+}
+
+// GetLogIDsForIncident returns the IDs of the logs of the incident, oldest first
+func (c *Client) GetLogIDsForIncident(ctx context.Context, incidentID string) ([]*IncidentLogID, error) {
+	logIDs, err := c.client.ZRangeWithScores(ctx, fmt.Sprintf("logs:%s", incidentID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching log IDs for incident ID: %s. Error: %w", incidentID, err)
+	}
+
+	var res []*IncidentLogID
+
+	for _, logID := range logIDs {
+		if id, ok := logID.Member.(string); ok {
+			res = append(res, &IncidentLogID{
+				LogID:     id,
+				Timestamp: int64(logID.Score),
+			})
+		}
+	}
+
+	return res, nil
 }
@@ -0,0 +1,162 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/porter-dev/porter-agent/pkg/utils"
+)
+
+// incidents are listed from sorted sets of incident IDs scored by the time the incidents were
+// created, instead of scanning the keys of Redis. Incidents expire once their retention has passed
+// since they were created, so older entries are trimmed from the indexes whenever they are read.
+const (
+	// all incidents
+	incidentsIndexKey = "incidents"
+
+	// the incidents which are active for their release
+	activeIncidentsIndexKey = "active_incidents"
+
+	// set once the indexes were built from the existing keys
+	indexesBuiltKey = "incident_indexes_built"
+)
+
+// the incidents of a release in a namespace
+func releaseIncidentsIndexKey(releaseName, namespace string) string {
+	return fmt.Sprintf("incidents:%s:%s", releaseName, namespace)
+}
+
+// EnsureIndexes builds the indexes of incidents from the existing keys, unless they were built before
+func (c *Client) EnsureIndexes(ctx context.Context) error {
+	built, err := c.client.Exists(ctx, indexesBuiltKey).Result()
+	if err != nil {
+		return fmt.Errorf("error checking if incident indexes were built. Error: %w", err)
+	}
+
+	if built != 0 {
+		return nil
+	}
+
+	return c.RebuildIndexes(ctx)
+}
+
+// RebuildIndexes adds the existing incidents and active incidents to the indexes of incidents. Keys
+// are scanned in batches, so that Redis is not blocked while the indexes are rebuilt.
+func (c *Client) RebuildIndexes(ctx context.Context) error {
+	iter := c.client.Scan(ctx, 0, "incident:*:*:*", 100).Iterator()
+
+	for iter.Next(ctx) {
+		if err := c.indexIncident(ctx, iter.Val()); err != nil {
+			return err
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("error scanning incidents. Error: %w", err)
+	}
+
+	iter = c.client.Scan(ctx, 0, "active_incident:*:*", 100).Iterator()
+
+	for iter.Next(ctx) {
+		incidentID, err := c.client.Get(ctx, iter.Val()).Result()
+		if err == goredis.Nil {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("error fetching active incident %s. Error: %w", iter.Val(), err)
+		}
+
+		if err := c.indexActiveIncident(ctx, incidentID); err != nil {
+			return err
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("error scanning active incidents. Error: %w", err)
+	}
+
+	_, err := c.client.Set(ctx, indexesBuiltKey, time.Now().Unix(), 0).Result()
+	if err != nil {
+		return fmt.Errorf("error marking incident indexes as built. Error: %w", err)
+	}
+
+	return nil
+}
+
+// indexIncident adds the incident to the index of all incidents and to the index of its release
+func (c *Client) indexIncident(ctx context.Context, incidentID string) error {
+	incidentObj, err := utils.NewIncidentFromString(incidentID)
+	if err != nil {
+		return err
+	}
+
+	member := &goredis.Z{
+		Score:  float64(incidentObj.GetTimestamp()),
+		Member: incidentID,
+	}
+
+	releaseKey := releaseIncidentsIndexKey(incidentObj.GetReleaseName(), incidentObj.GetNamespace())
+
+	_, err = c.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.ZAdd(ctx, incidentsIndexKey, member)
+		pipe.ZAdd(ctx, releaseKey, member)
+
+		// the index of a release is gone once all of its incidents have expired
+		pipe.ExpireAt(ctx, releaseKey, incidentObj.GetTimestampAsTime().Add(c.incidentTTL))
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error indexing incident with ID: %s. Error: %w", incidentID, err)
+	}
+
+	return nil
+}
+
+func (c *Client) indexActiveIncident(ctx context.Context, incidentID string) error {
+	incidentObj, err := utils.NewIncidentFromString(incidentID)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.ZAdd(ctx, activeIncidentsIndexKey, &goredis.Z{
+		Score:  float64(incidentObj.GetTimestamp()),
+		Member: incidentID,
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("error indexing active incident with ID: %s. Error: %w", incidentID, err)
+	}
+
+	return nil
+}
+
+func (c *Client) unindexActiveIncident(ctx context.Context, incidentID string) error {
+	_, err := c.client.ZRem(ctx, activeIncidentsIndexKey, incidentID).Result()
+	if err != nil {
+		return fmt.Errorf("error removing active incident with ID: %s from index. Error: %w", incidentID, err)
+	}
+
+	return nil
+}
+
+// getIndexedIncidents returns the incidents of the index which have not expired, latest first
+func (c *Client) getIndexedIncidents(ctx context.Context, key string) ([]string, error) {
+	minScore := strconv.FormatInt(time.Now().Add(-c.incidentTTL).Unix(), 10)
+
+	_, err := c.client.ZRemRangeByScore(ctx, key, "-inf", "("+minScore).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error removing expired incidents from index %s. Error: %w", key, err)
+	}
+
+	incidents, err := c.client.ZRevRangeByScore(ctx, key, &goredis.ZRangeBy{
+		Min: minScore,
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching incidents from index %s. Error: %w", key, err)
+	}
+
+	return incidents, nil
+}
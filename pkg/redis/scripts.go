@@ -0,0 +1,143 @@
+package redis
+
+import goredis "github.com/go-redis/redis/v8"
+
+// the state transitions of incidents run as Lua scripts, so that concurrent reconciles and agent
+// replicas cannot create duplicate incidents or resolve an incident twice
+
+// getOrCreateActiveIncidentScript returns the active incident of the release and whether it was
+// created by the script.
+//
+// KEYS: active incident, active incidents index
+// ARGV: new incident ID, TTL in seconds, creation timestamp
+var getOrCreateActiveIncidentScript = goredis.NewScript(`
+local incidentID = redis.call('GET', KEYS[1])
+if incidentID then
+	return {incidentID, 0}
+end
+
+redis.call('SET', KEYS[1], ARGV[1], 'EX', ARGV[2])
+redis.call('ZADD', KEYS[2], ARGV[3], ARGV[1])
+
+return {ARGV[1], 1}
+`)
+
+// addEventToIncidentScript adds the event to the incident and its pod to the pods of the incident,
+// and keeps the highest severity of the events of the incident along with the severity set by the
+// incident policy on the latest event. It returns 0 if the incident already holds the maximum number of events, and -1 if the incident
+// is no longer the active incident of its release, since it was resolved in the meantime.
+//
+// KEYS: incident, pods of the incident, incidents index, release incidents index, pending queue,
+// active incident, severity of the incident
+// ARGV: "1" if the incident is new, max event count, event score, event JSON, pod name,
+// expiry timestamp, incident timestamp, event severity, level of the event severity, policy
+// severity
+var addEventToIncidentScript = goredis.NewScript(`
+local newIncident = ARGV[1] == '1'
+
+if redis.call('GET', KEYS[6]) ~= KEYS[1] then
+	return -1
+end
+
+if not newIncident and redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+
+redis.call('ZADD', KEYS[1], ARGV[3], ARGV[4])
+redis.call('SADD', KEYS[2], ARGV[5])
+
+redis.call('EXPIREAT', KEYS[1], ARGV[6])
+redis.call('EXPIREAT', KEYS[2], ARGV[6])
+
+local level = redis.call('HGET', KEYS[7], 'level')
+if not level or tonumber(ARGV[9]) > tonumber(level) then
+	redis.call('HSET', KEYS[7], 'severity', ARGV[8], 'level', ARGV[9])
+end
+redis.call('HSET', KEYS[7], 'policy_severity', ARGV[10])
+redis.call('EXPIREAT', KEYS[7], ARGV[6])
+
+redis.call('ZADD', KEYS[3], ARGV[7], KEYS[1])
+redis.call('ZADD', KEYS[4], ARGV[7], KEYS[1])
+redis.call('EXPIREAT', KEYS[4], ARGV[6])
+
+if newIncident then
+	-- the new incident gets pushed out as a notification
+	redis.call('ZADD', KEYS[5], ARGV[3], 'new:' .. KEYS[1])
+end
+
+return 1
+`)
+
+// setPodResolvedScript removes the pod from the pods of the incident and resolves the incident
+// once none of its pods are affected. It returns -1 if the incident does not exist and 1 if the
+// incident was resolved.
+//
+// KEYS: incident, pods of the incident, active incident, active incidents index, pending queue
+// ARGV: pod name, resolution timestamp
+var setPodResolvedScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+
+if redis.call('SREM', KEYS[2], ARGV[1]) == 0 or redis.call('SCARD', KEYS[2]) > 0 then
+	return 0
+end
+
+-- a newer incident of the release may be active by now
+if redis.call('GET', KEYS[3]) == KEYS[1] then
+	redis.call('DEL', KEYS[3])
+end
+
+redis.call('ZREM', KEYS[4], KEYS[1])
+redis.call('ZADD', KEYS[5], ARGV[2], 'resolved:' .. KEYS[1])
+
+return 1
+`)
+
+// setJobIncidentResolvedScript resolves the incident regardless of its pods. It returns -1 if the
+// incident does not exist and 0 if it was resolved before.
+//
+// KEYS: incident, pods of the incident, active incident, active incidents index, pending queue
+// ARGV: resolution timestamp
+var setJobIncidentResolvedScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+
+local resolved = redis.call('DEL', KEYS[2])
+
+if redis.call('GET', KEYS[3]) == KEYS[1] then
+	redis.call('DEL', KEYS[3])
+	resolved = 1
+end
+
+if resolved == 0 then
+	return 0
+end
+
+redis.call('ZREM', KEYS[4], KEYS[1])
+redis.call('ZADD', KEYS[5], ARGV[1], 'resolved:' .. KEYS[1])
+
+return 1
+`)
+
+// addLogsScript stores the logs and adds their ID to the logs of the incident. Logs are keyed by
+// their content, so logs which are already stored keep their expiry unless the incident expires
+// later, and events never refer to expired logs.
+//
+// KEYS: logs, logs of the incident
+// ARGV: compressed logs, logs expiry timestamp, current timestamp, incident expiry timestamp
+var addLogsScript = goredis.NewScript(`
+local ttl = redis.call('TTL', KEYS[1])
+if ttl == -2 then
+	redis.call('SET', KEYS[1], ARGV[1])
+	redis.call('EXPIREAT', KEYS[1], ARGV[2])
+elseif ttl >= 0 and tonumber(ARGV[3]) + ttl < tonumber(ARGV[2]) then
+	redis.call('EXPIREAT', KEYS[1], ARGV[2])
+end
+
+redis.call('ZADD', KEYS[2], ARGV[3], KEYS[1])
+redis.call('EXPIREAT', KEYS[2], ARGV[4])
+
+return 1
+`)
@@ -3,14 +3,20 @@ package handlers
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/porter-dev/porter-agent/pkg/models"
 	"github.com/porter-dev/porter-agent/pkg/utils"
 )
 
-func GetAllIncidents(c *gin.Context) {
-	incidentIDs, err := redisClient.GetAllIncidents(c.Copy())
+func (h *IncidentHandler) GetAllIncidents(c *gin.Context) {
+	minSeverity, ok := getSeverityFilter(c)
+	if !ok {
+		return
+	}
+
+	incidentIDs, err := h.store.GetAllIncidents(c.Copy())
 	if err != nil {
 		httpLogger.Error(err, "error getting list of all incidents")
 
@@ -23,7 +29,7 @@ func GetAllIncidents(c *gin.Context) {
 	var incidents []*models.Incident
 
 	for _, id := range incidentIDs {
-		incident, err := redisClient.GetIncidentDetails(c.Copy(), id)
+		incident, err := h.store.GetIncidentDetails(c.Copy(), id)
 		if err != nil {
 			httpLogger.Error(err, "error getting incident details")
 
@@ -33,6 +39,10 @@ func GetAllIncidents(c *gin.Context) {
 			return
 		}
 
+		if minSeverity != "" && !incident.Severity.AtLeast(minSeverity) {
+			continue
+		}
+
 		incidents = append(incidents, incident)
 	}
 
@@ -41,11 +51,16 @@ func GetAllIncidents(c *gin.Context) {
 	})
 }
 
-func GetIncidentsByReleaseNamespace(c *gin.Context) {
+func (h *IncidentHandler) GetIncidentsByReleaseNamespace(c *gin.Context) {
 	releaseName := c.Param("releaseName")
 	namespace := c.Param("namespace")
 
-	incidentIDs, err := redisClient.GetIncidentsByReleaseNamespace(c.Copy(), releaseName, namespace)
+	minSeverity, ok := getSeverityFilter(c)
+	if !ok {
+		return
+	}
+
+	incidentIDs, err := h.store.GetIncidentsByReleaseNamespace(c.Copy(), releaseName, namespace)
 	if err != nil {
 		httpLogger.Error(err, "error getting incidents for release", "releaseName", releaseName)
 
@@ -58,7 +73,7 @@ func GetIncidentsByReleaseNamespace(c *gin.Context) {
 	var incidents []*models.Incident
 
 	for _, id := range incidentIDs {
-		incident, err := redisClient.GetIncidentDetails(c.Copy(), id)
+		incident, err := h.store.GetIncidentDetails(c.Copy(), id)
 		if err != nil {
 			httpLogger.Error(err, "error getting incident details")
 
@@ -68,6 +83,10 @@ func GetIncidentsByReleaseNamespace(c *gin.Context) {
 			return
 		}
 
+		if minSeverity != "" && !incident.Severity.AtLeast(minSeverity) {
+			continue
+		}
+
 		incidents = append(incidents, incident)
 	}
 
@@ -76,10 +95,15 @@ func GetIncidentsByReleaseNamespace(c *gin.Context) {
 	})
 }
 
-func GetIncidentEventsByID(c *gin.Context) {
+func (h *IncidentHandler) GetIncidentEventsByID(c *gin.Context) {
 	incidentID := c.Param("incidentID")
 
-	exists, err := redisClient.IncidentExists(c.Copy(), incidentID)
+	minSeverity, ok := getSeverityFilter(c)
+	if !ok {
+		return
+	}
+
+	exists, err := h.store.IncidentExists(c.Copy(), incidentID)
 	if err != nil {
 		httpLogger.Error(err, "error checking for existence of incident", "incidentID", incidentID)
 
@@ -96,7 +120,7 @@ func GetIncidentEventsByID(c *gin.Context) {
 		return
 	}
 
-	events, err := redisClient.GetIncidentEventsByID(c.Copy(), incidentID)
+	events, err := h.store.GetIncidentEventsByID(c.Copy(), incidentID)
 	if err != nil {
 		httpLogger.Error(err, "error getting events for incident", "incidentID", incidentID)
 
@@ -106,7 +130,7 @@ func GetIncidentEventsByID(c *gin.Context) {
 		return
 	}
 
-	resolved, err := redisClient.IsIncidentResolved(c.Copy(), incidentID)
+	resolved, err := h.store.IsIncidentResolved(c.Copy(), incidentID)
 	if err != nil {
 		httpLogger.Error(err, "error checking if incident is resolved", "incidentID", incidentID)
 
@@ -122,7 +146,7 @@ func GetIncidentEventsByID(c *gin.Context) {
 		latestState = "RESOLVED"
 	}
 
-	latestEvent, err := redisClient.GetLatestEventForIncident(c.Copy(), incidentID)
+	latestEvent, err := h.store.GetLatestEventForIncident(c.Copy(), incidentID)
 	if err != nil {
 		httpLogger.Error(err, "error fetching latest event", "incidentID", incidentID)
 
@@ -142,6 +166,37 @@ func GetIncidentEventsByID(c *gin.Context) {
 		return
 	}
 
+	eventsSeverity, policySeverity, err := h.store.GetIncidentSeverity(c.Copy(), incidentID)
+	if err != nil {
+		httpLogger.Error(err, "error fetching incident severity", "incidentID", incidentID)
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	// a resolved incident no longer escalates
+	until := time.Now()
+
+	if resolved {
+		until = time.Unix(latestEvent.Timestamp, 0)
+	}
+
+	severity := utils.GetIncidentSeverity(eventsSeverity, policySeverity, incidentObj.GetTimestampAsTime(), until)
+
+	if minSeverity != "" {
+		var filteredEvents []*models.PodEvent
+
+		for _, event := range events {
+			if event.Severity.AtLeast(minSeverity) {
+				filteredEvents = append(filteredEvents, event)
+			}
+		}
+
+		events = filteredEvents
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"incident_id":    incidentID,
 		"release_name":   strings.Split(incidentID, ":")[1],
@@ -151,14 +206,35 @@ func GetIncidentEventsByID(c *gin.Context) {
 		"latest_state":   latestState,
 		"latest_reason":  latestEvent.Reason,
 		"latest_message": latestEvent.Message,
+		"severity":       severity,
 		"events":         events,
 	})
 }
 
-func GetLogs(c *gin.Context) {
+// getSeverityFilter returns the minimum severity given by the "severity" query parameter, if any.
+// It responds with an error and returns false if the severity is invalid.
+func getSeverityFilter(c *gin.Context) (models.Severity, bool) {
+	name := c.Query("severity")
+
+	if name == "" {
+		return "", true
+	}
+
+	severity, ok := models.ParseSeverity(name)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid severity, must be one of low, medium, high or critical",
+		})
+		return "", false
+	}
+
+	return severity, true
+}
+
+func (h *IncidentHandler) GetLogs(c *gin.Context) {
 	logID := c.Param("logID")
 
-	logs, err := redisClient.GetLogs(c.Copy(), logID)
+	logs, err := h.store.GetLogs(c.Copy(), logID)
 	if err != nil {
 		if strings.Contains(err.Error(), "no such logs") {
 			httpLogger.Error(err, "no such logs", "logID", logID)
@@ -1,27 +1,19 @@
 package handlers
 
 import (
-	"github.com/porter-dev/porter-agent/pkg/redis"
-	"github.com/spf13/viper"
+	"github.com/porter-dev/porter-agent/pkg/store"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
-var (
-	redisHost    string
-	redisPort    string
-	maxTailLines int64
+var httpLogger = ctrl.Log.WithName("HTTP Server")
 
-	redisClient *redis.Client
-
-	httpLogger = ctrl.Log.WithName("HTTP Server")
-)
-
-func init() {
-	viper.SetDefault("REDIS_HOST", "porter-redis-master")
-	viper.SetDefault("REDIS_PORT", "6379")
-	maxTailLines = viper.GetInt64("MAX_TAIL_LINES")
+// IncidentHandler serves the incidents of the incident store
+type IncidentHandler struct {
+	store store.IncidentStore
+}
 
-	redisHost = viper.GetString("REDIS_HOST")
-	redisPort = viper.GetString("REDIS_PORT")
-	redisClient = redis.NewClient(redisHost, redisPort, "", "", redis.PODSTORE, maxTailLines)
+func NewIncidentHandler(incidentStore store.IncidentStore) *IncidentHandler {
+	return &IncidentHandler{
+		store: incidentStore,
+	}
 }
@@ -3,15 +3,18 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/porter-dev/porter-agent/pkg/server/handlers"
+	"github.com/porter-dev/porter-agent/pkg/store"
 )
 
-func NewRouter() *gin.Engine {
+func NewRouter(incidentStore store.IncidentStore) *gin.Engine {
 	router := gin.Default()
 
-	router.GET("/incidents", handlers.GetAllIncidents)
-	router.GET("/incidents/:incidentID", handlers.GetIncidentEventsByID)
-	router.GET("/incidents/namespaces/:namespace/releases/:releaseName", handlers.GetIncidentsByReleaseNamespace)
-	router.GET("/incidents/logs/:logID", handlers.GetLogs)
+	h := handlers.NewIncidentHandler(incidentStore)
+
+	router.GET("/incidents", h.GetAllIncidents)
+	router.GET("/incidents/:incidentID", h.GetIncidentEventsByID)
+	router.GET("/incidents/namespaces/:namespace/releases/:releaseName", h.GetIncidentsByReleaseNamespace)
+	router.GET("/incidents/logs/:logID", h.GetLogs)
 
 	return router
 }
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/spf13/viper"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var (
+	archivePath     string
+	archiveInterval time.Duration
+
+	archiveLog = ctrl.Log.WithName("incident-archiver")
+)
+
+func init() {
+	viper.SetDefault("ARCHIVE_PATH", "")
+	viper.SetDefault("ARCHIVE_INTERVAL", "1h")
+	viper.AutomaticEnv()
+
+	archivePath = viper.GetString("ARCHIVE_PATH")
+	archiveInterval = viper.GetDuration("ARCHIVE_INTERVAL")
+
+	if archivePath != "" && archiveInterval <= 0 {
+		panic(fmt.Sprintf("invalid ARCHIVE_INTERVAL %q, must be a positive duration", viper.GetString("ARCHIVE_INTERVAL")))
+	}
+}
+
+// ArchiveEnabled returns true if incidents are archived to ARCHIVE_PATH before they expire
+func ArchiveEnabled() bool {
+	return archivePath != ""
+}
+
+// archivedIncident is a line of the archive
+type archivedIncident struct {
+	ArchivedAt int64              `json:"archived_at"`
+	Incident   *models.Incident   `json:"incident"`
+	Events     []*models.PodEvent `json:"events"`
+}
+
+// Archiver appends the incidents which are about to expire, along with their events, to a JSONL
+// file, so that their history can be kept for longer than the retention of the incident store.
+// If ARCHIVE_PATH is a directory, the incidents are archived to a file per day.
+//
+// Incidents are archived once, within two archive intervals of their expiry. An incident may be
+// archived again if the agent restarts in the meantime, so the lines should be keyed by incident ID.
+type Archiver struct {
+	store    IncidentStore
+	archived map[string]bool
+}
+
+func NewArchiver(incidentStore IncidentStore) *Archiver {
+	return &Archiver{
+		store:    incidentStore,
+		archived: make(map[string]bool),
+	}
+}
+
+// Start archives the incidents which are about to expire every ARCHIVE_INTERVAL until the context
+// is done
+func (a *Archiver) Start(ctx context.Context) {
+	ticker := time.NewTicker(archiveInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.archiveExpiring(ctx, time.Now()); err != nil {
+			archiveLog.Error(err, "error archiving incidents")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Archiver) archiveExpiring(ctx context.Context, now time.Time) error {
+	incidentIDs, err := a.store.GetAllIncidents(ctx)
+	if err != nil {
+		return err
+	}
+
+	var lines []*archivedIncident
+	listed := make(map[string]bool)
+
+	for _, incidentID := range incidentIDs {
+		listed[incidentID] = true
+
+		// the incident has to be archived before the run after the next one
+		if a.archived[incidentID] || getIncidentExpiry(incidentID).After(now.Add(2*archiveInterval)) {
+			continue
+		}
+
+		incident, err := a.store.GetIncidentDetails(ctx, incidentID)
+		if err != nil {
+			archiveLog.Error(err, "error fetching incident to archive", "incidentID", incidentID)
+			continue
+		}
+
+		events, err := a.store.GetIncidentEventsByID(ctx, incidentID)
+		if err != nil {
+			archiveLog.Error(err, "error fetching events of incident to archive", "incidentID", incidentID)
+			continue
+		}
+
+		lines = append(lines, &archivedIncident{
+			ArchivedAt: now.Unix(),
+			Incident:   incident,
+			Events:     events,
+		})
+	}
+
+	// expired incidents are no longer listed
+	for incidentID := range a.archived {
+		if !listed[incidentID] {
+			delete(a.archived, incidentID)
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	if err := appendToArchive(getArchiveFile(now), lines); err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		a.archived[line.Incident.ID] = true
+	}
+
+	archiveLog.Info("archived incidents", "count", len(lines))
+
+	return nil
+}
+
+// getArchiveFile returns the file which incidents archived at the given time are appended to
+func getArchiveFile(now time.Time) string {
+	if info, err := os.Stat(archivePath); (err == nil && info.IsDir()) || strings.HasSuffix(archivePath, "/") {
+		return filepath.Join(archivePath, fmt.Sprintf("incidents-%s.jsonl", now.UTC().Format("2006-01-02")))
+	}
+
+	return archivePath
+}
+
+func appendToArchive(path string, lines []*archivedIncident) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating archive directory for %s. Error: %w", path, err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening archive %s. Error: %w", path, err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+
+	for _, line := range lines {
+		if err := encoder.Encode(line); err != nil {
+			return fmt.Errorf("error writing incident %s to archive %s. Error: %w", line.Incident.ID, path, err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("error writing to archive %s. Error: %w", path, err)
+	}
+
+	return file.Close()
+}
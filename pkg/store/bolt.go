@@ -0,0 +1,1014 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	porterErrors "github.com/porter-dev/porter-agent/pkg/errors"
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/porter-dev/porter-agent/pkg/utils"
+	bolt "go.etcd.io/bbolt"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// how often expired data is removed from the database, in between it is skipped when read
+const boltPurgeInterval = time.Minute * 10
+
+var (
+	// the queue of pending notifications is kept in two buckets, one with the packed items as keys
+	// and their scores as values, and one keyed by the score followed by the item, which sorts the
+	// items like a sorted set of Redis
+	boltPendingBucket      = []byte("pending")
+	boltPendingQueueBucket = []byte("pending_queue")
+
+	boltMetaBucket                = []byte("meta")
+	boltAgentCreationTimestampKey = []byte("agent_creation_timestamp")
+
+	// incidents are nested buckets keyed by their ID, which hold the expiry and severity of the
+	// incident and the buckets of its events, pods and log IDs. Events and log IDs are keyed by
+	// their score followed by a sequence number, so that they are sorted like in a sorted set of
+	// Redis.
+	boltIncidentsBucket   = []byte("incidents")
+	boltEventsBucket      = []byte("events")
+	boltPodsBucket        = []byte("pods")
+	boltLogIDsBucket      = []byte("log_ids")
+	boltExpiresAtKey      = []byte("expires_at")
+	boltSeverityKey       = []byte("severity")
+	boltPolicySeverityKey = []byte("policy_severity")
+
+	// the values of these buckets are prefixed with their expiry
+	boltActiveIncidentsBucket = []byte("active_incidents")
+	boltLogsBucket            = []byte("logs")
+	boltRestartCountsBucket   = []byte("restart_counts")
+	boltRestartHistoryBucket  = []byte("restart_history")
+
+	boltLog = ctrl.Log.WithName("bolt-store")
+)
+
+// BoltStore is an IncidentStore which keeps its data in an embedded bbolt database on disk, so that
+// the agent can run without Redis and still keep its incidents when it restarts
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens the database at the path, creating it if it does not exist. Only one process
+// can open the database at a time.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second * 10})
+	if err != nil {
+		return nil, fmt.Errorf("error opening bolt database at %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{
+			boltPendingBucket, boltMetaBucket, boltIncidentsBucket, boltActiveIncidentsBucket,
+			boltLogsBucket, boltRestartCountsBucket, boltRestartHistoryBucket,
+		} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		if tx.Bucket(boltPendingQueueBucket) != nil {
+			return nil
+		}
+
+		// databases of earlier versions of the agent only hold the scores of pending items
+		queue, err := tx.CreateBucket(boltPendingQueueBucket)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(boltPendingBucket).ForEach(func(k, v []byte) error {
+			return queue.Put(pendingQueueKey(decodeScore(v), k), []byte{})
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating buckets of bolt database at %s: %w", path, err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Close closes the database
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// PurgeExpiredPeriodically removes the expired data from the database until the context is done
+func (s *BoltStore) PurgeExpiredPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(boltPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.purgeExpired(); err != nil {
+				boltLog.Error(err, "error removing expired data")
+			}
+		}
+	}
+}
+
+func (s *BoltStore) AppendToNotifyWorkQueue(ctx context.Context, packed []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putPendingItem(tx, packed, float64(time.Now().Unix()))
+	})
+}
+
+func (s *BoltStore) GetItemFromPendingQueue(ctx context.Context) ([]byte, float64, error) {
+	var item []byte
+	var score float64
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		pending := tx.Bucket(boltPendingBucket)
+		queue := tx.Bucket(boltPendingQueueBucket)
+
+		// the first key holds the lowest score, and among the items with that score the first one
+		// in lexicographical order, same as in Redis
+		k, _ := queue.Cursor().First()
+		if k == nil {
+			return porterErrors.NoPendingItemError
+		}
+
+		item = append([]byte{}, k[8:]...)
+		score = decodeScore(pending.Get(item))
+
+		if err := queue.Delete(k); err != nil {
+			return err
+		}
+
+		return pending.Delete(item)
+	})
+	if err != nil {
+		return []byte{}, 0, err
+	}
+
+	return item, score, nil
+}
+
+func (s *BoltStore) RequeueItemWithScore(ctx context.Context, packed []byte, score float64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putPendingItem(tx, packed, score)
+	})
+}
+
+func (s *BoltStore) IsFirstRun(ctx context.Context) (bool, error) {
+	firstRun := false
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		firstRun = tx.Bucket(boltMetaBucket).Get(boltAgentCreationTimestampKey) == nil
+		return nil
+	})
+
+	return firstRun, err
+}
+
+func (s *BoltStore) SetAgentCreationTimestamp(ctx context.Context) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(boltMetaBucket)
+
+		if meta.Get(boltAgentCreationTimestampKey) != nil {
+			return fmt.Errorf("agent timestamp already exists in bolt store")
+		}
+
+		return meta.Put(boltAgentCreationTimestampKey, []byte(strconv.FormatInt(time.Now().Unix(), 10)))
+	})
+}
+
+func (s *BoltStore) GetAgentCreationTimestamp(ctx context.Context) (int64, error) {
+	var timestamp int64
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(boltMetaBucket)
+
+		value := meta.Get(boltAgentCreationTimestampKey)
+		if value == nil {
+			timestamp = time.Now().Unix()
+			return meta.Put(boltAgentCreationTimestampKey, []byte(strconv.FormatInt(timestamp, 10)))
+		}
+
+		var err error
+		timestamp, err = strconv.ParseInt(string(value), 10, 64)
+
+		return err
+	})
+
+	return timestamp, err
+}
+
+func (s *BoltStore) IncidentExists(ctx context.Context, incident string) (bool, error) {
+	exists := false
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		exists = boltIncidentExists(getIncidentBucket(tx, incident))
+		return nil
+	})
+
+	return exists, err
+}
+
+func (s *BoltStore) GetLatestEventForIncident(ctx context.Context, incidentID string) (*models.PodEvent, error) {
+	var event *models.PodEvent
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		incident := getIncidentBucket(tx, incidentID)
+		if incident == nil {
+			return nil
+		}
+
+		_, v := incident.Bucket(boltEventsBucket).Cursor().Last()
+		if v == nil {
+			// no latest event exists, possibly a new incident
+			return nil
+		}
+
+		event = &models.PodEvent{}
+
+		if err := json.Unmarshal(v, event); err != nil {
+			return fmt.Errorf("error unmarshalling event to json for incident ID: %s. Error: %w", incidentID, err)
+		}
+
+		return nil
+	})
+
+	return event, err
+}
+
+func (s *BoltStore) AddEventToIncident(
+	ctx context.Context, incidentID string, event *models.PodEvent, newIncident bool,
+) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if !isBoltActiveIncident(tx, incidentID) {
+			return fmt.Errorf("%w: incident ID: %s", porterErrors.IncidentNotActiveError, incidentID)
+		}
+
+		incident, err := createIncidentBucket(tx, incidentID)
+		if err != nil {
+			return err
+		}
+
+		events := incident.Bucket(boltEventsBucket)
+
+		if !newIncident && events.Stats().KeyN >= maxIncidentEvents {
+			return fmt.Errorf("%w of %d for incident ID: %s", porterErrors.MaxEventCountError, maxIncidentEvents, incidentID)
+		}
+
+		score := time.Now().Unix()
+
+		event.EventID = fmt.Sprintf("%s:%d", incidentID, score)
+
+		eventJSON, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("error marshalling to JSON with event ID: %s. Error: %w", event.EventID, err)
+		}
+
+		if err := putScored(events, score, eventJSON); err != nil {
+			return fmt.Errorf("error adding new pod event to incident with ID: %s. Error: %w", incidentID, err)
+		}
+
+		if err := incident.Bucket(boltPodsBucket).Put([]byte(event.PodName), []byte{}); err != nil {
+			return fmt.Errorf("error adding new pod: %s to pod set with incident ID: %s. Error: %w",
+				event.PodName, incidentID, err)
+		}
+
+		if err := putBoltIncidentSeverity(incident, event); err != nil {
+			return fmt.Errorf("error updating severity of incident with ID: %s. Error: %w", incidentID, err)
+		}
+
+		if newIncident {
+			// we need to add this new incident to the pending queue so that it gets pushed out as a notification
+			return putPendingItem(tx, []byte("new:"+incidentID), float64(time.Now().Unix()))
+		}
+
+		return nil
+	})
+}
+
+func (s *BoltStore) SetPodResolved(ctx context.Context, podName, incidentID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		incident := getIncidentBucket(tx, incidentID)
+		if !boltIncidentExists(incident) {
+			return fmt.Errorf("trying to set pod resolved for non-existent incident with ID: %s", incidentID)
+		}
+
+		pods := incident.Bucket(boltPodsBucket)
+
+		if pods.Get([]byte(podName)) == nil {
+			return nil
+		}
+
+		if err := pods.Delete([]byte(podName)); err != nil {
+			return fmt.Errorf("error trying to set pod resolved for pod: %s for incident ID: %s", podName, incidentID)
+		}
+
+		if k, _ := pods.Cursor().First(); k == nil {
+			// all pods are now healthy, delete the active incident
+			return resolveBoltIncident(tx, incidentID)
+		}
+
+		return nil
+	})
+}
+
+func (s *BoltStore) SetJobIncidentResolved(ctx context.Context, incidentID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		incident := getIncidentBucket(tx, incidentID)
+		if !boltIncidentExists(incident) {
+			return fmt.Errorf("trying to set job incident resolved for non-existent incident with ID: %s", incidentID)
+		}
+
+		// the incident was resolved before
+		if k, _ := incident.Bucket(boltPodsBucket).Cursor().First(); k == nil && !isBoltActiveIncident(tx, incidentID) {
+			return nil
+		}
+
+		if err := incident.DeleteBucket(boltPodsBucket); err != nil {
+			return fmt.Errorf("error trying to remove pods for resolved job incident ID: %s. Error: %w", incidentID, err)
+		}
+
+		if _, err := incident.CreateBucket(boltPodsBucket); err != nil {
+			return err
+		}
+
+		return resolveBoltIncident(tx, incidentID)
+	})
+}
+
+func (s *BoltStore) GetIncidentDetails(ctx context.Context, incidentID string) (*models.Incident, error) {
+	return getIncidentDetails(ctx, s, incidentID)
+}
+
+func (s *BoltStore) IsIncidentResolved(ctx context.Context, incidentID string) (bool, error) {
+	resolved := true
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if incident := getIncidentBucket(tx, incidentID); incident != nil {
+			k, _ := incident.Bucket(boltPodsBucket).Cursor().First()
+			resolved = k == nil
+		}
+
+		return nil
+	})
+
+	return resolved, err
+}
+
+func (s *BoltStore) GetAllIncidents(ctx context.Context) ([]string, error) {
+	return s.listIncidents(func(*utils.Incident) bool { return true })
+}
+
+func (s *BoltStore) GetAllActiveIncidents(ctx context.Context) ([]string, error) {
+	var incidentIDs []string
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+
+		return tx.Bucket(boltActiveIncidentsBucket).ForEach(func(k, v []byte) error {
+			if expiresAt, value := decodeExpiring(v); !isExpired(expiresAt, now) {
+				incidentIDs = append(incidentIDs, string(value))
+			}
+
+			return nil
+		})
+	})
+
+	return incidentIDs, err
+}
+
+func (s *BoltStore) GetPodsForIncident(ctx context.Context, incidentID string) ([]string, error) {
+	var pods []string
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		incident := getIncidentBucket(tx, incidentID)
+		if incident == nil {
+			return nil
+		}
+
+		return incident.Bucket(boltPodsBucket).ForEach(func(k, v []byte) error {
+			pods = append(pods, string(k))
+			return nil
+		})
+	})
+
+	return pods, err
+}
+
+func (s *BoltStore) GetIncidentsByReleaseNamespace(
+	ctx context.Context, releaseName, namespace string,
+) ([]string, error) {
+	return s.listIncidents(func(incidentObj *utils.Incident) bool {
+		return incidentObj.GetReleaseName() == releaseName && incidentObj.GetNamespace() == namespace
+	})
+}
+
+func (s *BoltStore) GetIncidentEventsByID(ctx context.Context, incidentID string) ([]*models.PodEvent, error) {
+	var events []*models.PodEvent
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		incident := getIncidentBucket(tx, incidentID)
+		if incident == nil {
+			return nil
+		}
+
+		c := incident.Bucket(boltEventsBucket).Cursor()
+
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			event := &models.PodEvent{}
+
+			if err := json.Unmarshal(v, event); err != nil {
+				return fmt.Errorf("error unmarshalling event to json for incident ID: %s. Error: %w", incidentID, err)
+			}
+
+			events = append(events, event)
+		}
+
+		return nil
+	})
+
+	return events, err
+}
+
+func (s *BoltStore) GetIncidentSeverity(ctx context.Context, incidentID string) (models.Severity, models.Severity, error) {
+	var severity, policySeverity models.Severity
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		incident := getIncidentBucket(tx, incidentID)
+		if incident == nil {
+			return nil
+		}
+
+		var err error
+
+		severity, policySeverity, err = getBoltIncidentSeverity(incident)
+		if err != nil {
+			return fmt.Errorf("error getting severity of incident with ID: %s. Error: %w", incidentID, err)
+		}
+
+		return nil
+	})
+
+	return severity, policySeverity, err
+}
+
+func (s *BoltStore) AddLogs(ctx context.Context, incidentID, strLogs string) (string, error) {
+	compressed, err := utils.CompressLogs(strLogs)
+	if err != nil {
+		return "", err
+	}
+
+	logID := utils.NewLogID(strLogs)
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return putBoltLogs(tx, incidentID, logID, compressed, time.Now().Unix(), getLogExpiry(incidentID, time.Now()))
+	})
+	if err != nil {
+		return "", fmt.Errorf("error adding new log with ID: %s for incident ID: %s. Error: %w", logID, incidentID, err)
+	}
+
+	return logID, nil
+}
+
+func (s *BoltStore) GetLogs(ctx context.Context, logID string) (string, error) {
+	var logs []byte
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		expiresAt, value := decodeExpiring(tx.Bucket(boltLogsBucket).Get([]byte(logID)))
+		if value == nil || isExpired(expiresAt, time.Now()) {
+			return fmt.Errorf("no such logs with ID: %s", logID)
+		}
+
+		// the value is only valid during the transaction
+		logs = append([]byte{}, value...)
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return utils.DecompressLogs(logs)
+}
+
+func (s *BoltStore) GetActiveIncident(ctx context.Context, releaseName, namespace string) (string, error) {
+	var incidentID string
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		incidentID = getBoltActiveIncident(tx, releaseName, namespace)
+
+		if incidentID == "" {
+			return fmt.Errorf("error fetching active incident for %s in namespace %s: no active incident",
+				releaseName, namespace)
+		}
+
+		return nil
+	})
+
+	return incidentID, err
+}
+
+func (s *BoltStore) ActiveIncidentExists(ctx context.Context, releaseName, namespace string) (bool, error) {
+	exists := false
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		exists = getBoltActiveIncident(tx, releaseName, namespace) != ""
+		return nil
+	})
+
+	return exists, err
+}
+
+func (s *BoltStore) GetOrCreateActiveIncident(ctx context.Context, releaseName, namespace string) (string, bool, error) {
+	incidentID := ""
+	created := false
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if incidentID = getBoltActiveIncident(tx, releaseName, namespace); incidentID != "" {
+			return nil
+		}
+
+		incidentID = utils.NewIncident(releaseName, namespace, time.Now().Unix()).ToString()
+		created = true
+
+		return tx.Bucket(boltActiveIncidentsBucket).Put([]byte(activeIncidentKey(releaseName, namespace)),
+			encodeExpiring(time.Now().Add(incidentTTL), []byte(incidentID)))
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("error creating new active incident for release %s with namespace %s. Error: %w",
+			releaseName, namespace, err)
+	}
+
+	return incidentID, created, nil
+}
+
+func (s *BoltStore) GetLastRestartCount(
+	ctx context.Context, releaseName, namespace, podName, containerName string,
+) (int32, bool, error) {
+	var count int32
+	found := false
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		counts := make(map[string]int32)
+
+		if err := getBoltExpiringJSON(tx.Bucket(boltRestartCountsBucket), activeIncidentKey(releaseName, namespace), &counts); err != nil {
+			return err
+		}
+
+		count, found = counts[fmt.Sprintf("%s/%s", podName, containerName)]
+
+		return nil
+	})
+
+	return count, found, err
+}
+
+func (s *BoltStore) AddRestartSample(
+	ctx context.Context, releaseName, namespace string, sample *models.RestartSample, window time.Duration,
+) error {
+	key := activeIncidentKey(releaseName, namespace)
+	expiresAt := time.Now().Add(window)
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		counts := make(map[string]int32)
+		restartCounts := tx.Bucket(boltRestartCountsBucket)
+
+		if err := getBoltExpiringJSON(restartCounts, key, &counts); err != nil {
+			return err
+		}
+
+		counts[fmt.Sprintf("%s/%s", sample.PodName, sample.ContainerName)] = sample.RestartCount
+
+		if err := putBoltExpiringJSON(restartCounts, key, expiresAt, counts); err != nil {
+			return err
+		}
+
+		if sample.Restarts <= 0 {
+			return nil
+		}
+
+		var history []*models.RestartSample
+		restartHistory := tx.Bucket(boltRestartHistoryBucket)
+
+		if err := getBoltExpiringJSON(restartHistory, key, &history); err != nil {
+			return err
+		}
+
+		since := time.Now().Add(-window).Unix()
+		samples := []*models.RestartSample{sample}
+
+		for _, previous := range history {
+			if previous.Timestamp >= since && *previous != *sample {
+				samples = append(samples, previous)
+			}
+		}
+
+		sort.SliceStable(samples, func(i, j int) bool {
+			return samples[i].Timestamp < samples[j].Timestamp
+		})
+
+		return putBoltExpiringJSON(restartHistory, key, expiresAt, samples)
+	})
+}
+
+func (s *BoltStore) GetRestartHistory(
+	ctx context.Context, releaseName, namespace string, since time.Time,
+) ([]*models.RestartSample, error) {
+	var samples []*models.RestartSample
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var history []*models.RestartSample
+
+		if err := getBoltExpiringJSON(tx.Bucket(boltRestartHistoryBucket), activeIncidentKey(releaseName, namespace), &history); err != nil {
+			return err
+		}
+
+		for _, sample := range history {
+			if sample.Timestamp >= since.Unix() {
+				samples = append(samples, sample)
+			}
+		}
+
+		return nil
+	})
+
+	return samples, err
+}
+
+// listIncidents returns the IDs of the incidents which match the filter, latest first
+func (s *BoltStore) listIncidents(filter func(*utils.Incident) bool) ([]string, error) {
+	var incidents []string
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltIncidentsBucket).ForEach(func(k, v []byte) error {
+			incidentID := string(k)
+
+			if !boltIncidentExists(getIncidentBucket(tx, incidentID)) {
+				return nil
+			}
+
+			if incidentObj, err := utils.NewIncidentFromString(incidentID); err == nil && filter(incidentObj) {
+				incidents = append(incidents, incidentID)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortIncidents(incidents)
+
+	return incidents, nil
+}
+
+// purgeExpired removes everything which has expired, same as Redis would
+func (s *BoltStore) purgeExpired() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+
+		incidents := tx.Bucket(boltIncidentsBucket)
+
+		var expiredIncidents [][]byte
+
+		err := incidents.ForEach(func(k, v []byte) error {
+			if incident := incidents.Bucket(k); incident != nil &&
+				isExpired(decodeTime(incident.Get(boltExpiresAtKey)), now) {
+				expiredIncidents = append(expiredIncidents, append([]byte{}, k...))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range expiredIncidents {
+			if err := incidents.DeleteBucket(k); err != nil {
+				return err
+			}
+		}
+
+		for _, name := range [][]byte{
+			boltActiveIncidentsBucket, boltLogsBucket, boltRestartCountsBucket, boltRestartHistoryBucket,
+		} {
+			bucket := tx.Bucket(name)
+
+			var expired [][]byte
+
+			err := bucket.ForEach(func(k, v []byte) error {
+				if expiresAt, _ := decodeExpiring(v); isExpired(expiresAt, now) {
+					expired = append(expired, append([]byte{}, k...))
+				}
+
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, k := range expired {
+				if err := bucket.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// getIncidentBucket returns the bucket of the incident, or nil if it does not exist or has expired
+func getIncidentBucket(tx *bolt.Tx, incidentID string) *bolt.Bucket {
+	incident := tx.Bucket(boltIncidentsBucket).Bucket([]byte(incidentID))
+
+	if incident == nil || isExpired(decodeTime(incident.Get(boltExpiresAtKey)), time.Now()) {
+		return nil
+	}
+
+	return incident
+}
+
+// createIncidentBucket returns the bucket of the incident, creating it if it does not exist. The
+// incident expires once the configured incident retention has passed since it was created.
+func createIncidentBucket(tx *bolt.Tx, incidentID string) (*bolt.Bucket, error) {
+	if incident := getIncidentBucket(tx, incidentID); incident != nil {
+		return incident, nil
+	}
+
+	incidents := tx.Bucket(boltIncidentsBucket)
+
+	// an expired incident which was not purged yet is replaced
+	if incidents.Bucket([]byte(incidentID)) != nil {
+		if err := incidents.DeleteBucket([]byte(incidentID)); err != nil {
+			return nil, err
+		}
+	}
+
+	incident, err := incidents.CreateBucket([]byte(incidentID))
+	if err != nil {
+		return nil, fmt.Errorf("error creating incident with ID: %s. Error: %w", incidentID, err)
+	}
+
+	for _, name := range [][]byte{boltEventsBucket, boltPodsBucket, boltLogIDsBucket} {
+		if _, err := incident.CreateBucket(name); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := incident.Put(boltExpiresAtKey, encodeTime(getIncidentExpiry(incidentID))); err != nil {
+		return nil, err
+	}
+
+	return incident, nil
+}
+
+// boltIncidentExists returns whether an event was added to the incident, since the logs of an
+// incident may be added before its first event
+func boltIncidentExists(incident *bolt.Bucket) bool {
+	if incident == nil {
+		return false
+	}
+
+	k, _ := incident.Bucket(boltEventsBucket).Cursor().First()
+
+	return k != nil
+}
+
+// resolveBoltIncident removes the active incident of the release of the incident and queues the
+// notification of the resolved incident
+func resolveBoltIncident(tx *bolt.Tx, incidentID string) error {
+	if incidentObj, err := utils.NewIncidentFromString(incidentID); err == nil {
+		releaseName, namespace := incidentObj.GetReleaseName(), incidentObj.GetNamespace()
+
+		// a newer incident of the release may be active by now
+		if getBoltActiveIncident(tx, releaseName, namespace) == incidentID {
+			err = tx.Bucket(boltActiveIncidentsBucket).Delete([]byte(activeIncidentKey(releaseName, namespace)))
+			if err != nil {
+				return fmt.Errorf("error trying to remove %s from active_incident. Error: %w", incidentID, err)
+			}
+		}
+	}
+
+	return putPendingItem(tx, []byte("resolved:"+incidentID), float64(time.Now().Unix()))
+}
+
+func isBoltActiveIncident(tx *bolt.Tx, incidentID string) bool {
+	incidentObj, err := utils.NewIncidentFromString(incidentID)
+	if err != nil {
+		return false
+	}
+
+	return getBoltActiveIncident(tx, incidentObj.GetReleaseName(), incidentObj.GetNamespace()) == incidentID
+}
+
+func getBoltActiveIncident(tx *bolt.Tx, releaseName, namespace string) string {
+	expiresAt, value := decodeExpiring(tx.Bucket(boltActiveIncidentsBucket).Get(
+		[]byte(activeIncidentKey(releaseName, namespace))))
+
+	if value == nil || isExpired(expiresAt, time.Now()) {
+		return ""
+	}
+
+	return string(value)
+}
+
+// getBoltIncidentSeverity returns the highest severity of the events of the incident and the
+// severity set by the incident policy on its latest event. Incidents stored before the severity
+// was kept on the incident, including those imported from Redis, get it from their events.
+func getBoltIncidentSeverity(incident *bolt.Bucket) (models.Severity, models.Severity, error) {
+	if severity := incident.Get(boltSeverityKey); severity != nil {
+		return models.Severity(severity), models.Severity(incident.Get(boltPolicySeverityKey)), nil
+	}
+
+	var events []*models.PodEvent
+
+	err := incident.Bucket(boltEventsBucket).ForEach(func(k, v []byte) error {
+		event := &models.PodEvent{}
+
+		if err := json.Unmarshal(v, event); err != nil {
+			return err
+		}
+
+		events = append(events, event)
+
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	severity, policySeverity := utils.GetEventsSeverity(events)
+
+	return severity, policySeverity, nil
+}
+
+// putBoltIncidentSeverity updates the severity of the incident with the event added to it
+func putBoltIncidentSeverity(incident *bolt.Bucket, event *models.PodEvent) error {
+	severity, _, err := getBoltIncidentSeverity(incident)
+	if err != nil {
+		return err
+	}
+
+	severity = models.MaxSeverity(severity, utils.GetEventSeverity(event))
+
+	if err := incident.Put(boltSeverityKey, []byte(severity)); err != nil {
+		return err
+	}
+
+	return incident.Put(boltPolicySeverityKey, []byte(event.PolicySeverity))
+}
+
+func putBoltLogs(tx *bolt.Tx, incidentID, logID string, logs []byte, timestamp int64, expiresAt time.Time) error {
+	logsBucket := tx.Bucket(boltLogsBucket)
+
+	// logs which are already stored are kept for as long as they are referenced
+	if storedExpiresAt, stored := decodeExpiring(logsBucket.Get([]byte(logID))); stored != nil &&
+		storedExpiresAt.After(expiresAt) {
+		expiresAt = storedExpiresAt
+	}
+
+	if err := logsBucket.Put([]byte(logID), encodeExpiring(expiresAt, logs)); err != nil {
+		return err
+	}
+
+	incident, err := createIncidentBucket(tx, incidentID)
+	if err != nil {
+		return err
+	}
+
+	return putScored(incident.Bucket(boltLogIDsBucket), timestamp, []byte(logID))
+}
+
+// putPendingItem adds the item to the pending queue, or moves it to the score if it is queued
+// already, since an item is queued at most once like a member of a sorted set of Redis
+func putPendingItem(tx *bolt.Tx, packed []byte, score float64) error {
+	pending := tx.Bucket(boltPendingBucket)
+	queue := tx.Bucket(boltPendingQueueBucket)
+
+	if previous := pending.Get(packed); previous != nil {
+		if err := queue.Delete(pendingQueueKey(decodeScore(previous), packed)); err != nil {
+			return err
+		}
+	}
+
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, math.Float64bits(score))
+
+	if err := pending.Put(packed, value); err != nil {
+		return err
+	}
+
+	return queue.Put(pendingQueueKey(score, packed), []byte{})
+}
+
+// pendingQueueKey returns the key of the item in the pending queue, which is the score encoded so
+// that it sorts like a number, followed by the item
+func pendingQueueKey(score float64, packed []byte) []byte {
+	bits := math.Float64bits(score)
+
+	// the sign bit is flipped for positive scores and all bits for negative ones, so that
+	// negative scores sort before positive ones and in reverse order of their magnitude
+	if bits&(1<<63) == 0 {
+		bits |= 1 << 63
+	} else {
+		bits = ^bits
+	}
+
+	key := make([]byte, 8+len(packed))
+	binary.BigEndian.PutUint64(key, bits)
+	copy(key[8:], packed)
+
+	return key
+}
+
+func decodeScore(value []byte) float64 {
+	if len(value) != 8 {
+		return 0
+	}
+
+	return math.Float64frombits(binary.BigEndian.Uint64(value))
+}
+
+// putScored adds the value to the bucket under a key which sorts by the score, and then by the
+// order in which values were added
+func putScored(bucket *bolt.Bucket, score int64, value []byte) error {
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(score))
+	binary.BigEndian.PutUint64(key[8:], seq)
+
+	return bucket.Put(key, value)
+}
+
+func getBoltExpiringJSON(bucket *bolt.Bucket, key string, v interface{}) error {
+	expiresAt, value := decodeExpiring(bucket.Get([]byte(key)))
+	if value == nil || isExpired(expiresAt, time.Now()) {
+		return nil
+	}
+
+	return json.Unmarshal(value, v)
+}
+
+func putBoltExpiringJSON(bucket *bolt.Bucket, key string, expiresAt time.Time, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(key), encodeExpiring(expiresAt, value))
+}
+
+// encodeExpiring prefixes the value with the time at which it expires
+func encodeExpiring(expiresAt time.Time, value []byte) []byte {
+	return append(encodeTime(expiresAt), value...)
+}
+
+func decodeExpiring(data []byte) (time.Time, []byte) {
+	if len(data) < 8 {
+		return time.Time{}, nil
+	}
+
+	return decodeTime(data[:8]), data[8:]
+}
+
+// encodeTime encodes the time as unix seconds, where 0 is a time which never comes
+func encodeTime(t time.Time) []byte {
+	value := make([]byte, 8)
+
+	if !t.IsZero() {
+		binary.BigEndian.PutUint64(value, uint64(t.Unix()))
+	}
+
+	return value
+}
+
+func decodeTime(value []byte) time.Time {
+	if len(value) != 8 || binary.BigEndian.Uint64(value) == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(int64(binary.BigEndian.Uint64(value)), 0)
+}
+
+// getIDTimestamp returns the timestamp at the end of the ID of an event or of logs, which are of
+// the form "<incident_id>:<timestamp>" and "log:<incident_id>:<timestamp>"
+func getIDTimestamp(id string) int64 {
+	timestamp, _ := strconv.ParseInt(id[strings.LastIndex(id, ":")+1:], 10, 64)
+
+	return timestamp
+}
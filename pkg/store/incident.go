@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/porter-dev/porter-agent/pkg/utils"
+)
+
+// getIncidentDetails returns the details of the incident from the other methods of the store, the
+// same way for every backend
+func getIncidentDetails(ctx context.Context, s IncidentStore, incidentID string) (*models.Incident, error) {
+	if exists, err := s.IncidentExists(ctx, incidentID); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, fmt.Errorf("trying to get details of non-existent incident with ID: %s", incidentID)
+	}
+
+	incidentObj, err := utils.NewIncidentFromString(incidentID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting incident object for incident ID: %s. Error: %w", incidentID, err)
+	}
+
+	incident := &models.Incident{
+		ID:          incidentID,
+		ReleaseName: incidentObj.GetReleaseName(),
+		CreatedAt:   incidentObj.GetTimestamp(),
+	}
+
+	resolved, err := s.IsIncidentResolved(ctx, incidentID)
+	if err != nil {
+		return nil, fmt.Errorf("error checking if incident is resolved with incidentID: %s. Error: %w", incidentID, err)
+	}
+
+	if resolved {
+		incident.LatestState = "RESOLVED"
+	} else {
+		incident.LatestState = "ONGOING"
+	}
+
+	latestEvent, err := s.GetLatestEventForIncident(ctx, incidentID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching latest event with incidentID: %s. Error: %w", incidentID, err)
+	} else if latestEvent == nil {
+		return nil, fmt.Errorf("no events for incident with ID: %s", incidentID)
+	}
+
+	incident.ChartName = latestEvent.ChartName
+	incident.UpdatedAt = latestEvent.Timestamp
+
+	if incident.LatestState == "RESOLVED" {
+		incident.LatestReason = "Resolved"
+		incident.LatestMessage = "This incident has been resolved"
+	} else {
+		incident.LatestReason = latestEvent.Reason
+		incident.LatestMessage = utils.GetEventSummary(latestEvent)
+	}
+
+	severity, policySeverity, err := s.GetIncidentSeverity(ctx, incidentID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching severity with incidentID: %s. Error: %w", incidentID, err)
+	}
+
+	// a resolved incident no longer escalates
+	until := time.Now()
+
+	if resolved {
+		until = time.Unix(latestEvent.Timestamp, 0)
+	}
+
+	incident.Severity = utils.GetIncidentSeverity(severity, policySeverity, incidentObj.GetTimestampAsTime(), until)
+
+	return incident, nil
+}
+
+func activeIncidentKey(releaseName, namespace string) string {
+	return fmt.Sprintf("%s:%s", releaseName, namespace)
+}
+
+func isExpired(expiresAt, now time.Time) bool {
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
+
+// getIncidentExpiry returns when the incident expires, which is the configured incident retention
+// after it was created
+func getIncidentExpiry(incidentID string) time.Time {
+	incidentObj, err := utils.NewIncidentFromString(incidentID)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return incidentObj.GetTimestampAsTime().Add(incidentTTL)
+}
+
+// getLogExpiry returns when logs added to the incident at the given time expire, which is the
+// configured log retention after they were added but no earlier than the incident, so that the
+// events of the incident never refer to expired logs
+func getLogExpiry(incidentID string, addedAt time.Time) time.Time {
+	expiresAt := addedAt.Add(logTTL)
+
+	if incidentExpiresAt := getIncidentExpiry(incidentID); incidentExpiresAt.After(expiresAt) {
+		return incidentExpiresAt
+	}
+
+	return expiresAt
+}
+
+// sortIncidents sorts the incident IDs by the time the incidents were created, latest first
+func sortIncidents(incidents []string) {
+	sort.SliceStable(incidents, func(i, j int) bool {
+		objA, _ := utils.NewIncidentFromString(incidents[i])
+		objB, _ := utils.NewIncidentFromString(incidents[j])
+
+		return objA.GetTimestamp() > objB.GetTimestamp()
+	})
+}
@@ -0,0 +1,713 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	porterErrors "github.com/porter-dev/porter-agent/pkg/errors"
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/porter-dev/porter-agent/pkg/utils"
+)
+
+// MemoryStore is an IncidentStore which keeps its data in the memory of the agent, so that the agent
+// can run without Redis on small clusters and for local development. The data is lost whenever the
+// agent restarts.
+type MemoryStore struct {
+	mu sync.Mutex
+
+	pending                *sortedSet
+	agentCreationTimestamp int64
+
+	incidents       map[string]*memoryIncident
+	activeIncidents map[string]*expiringValue
+	logs            map[string]*expiringValue
+	restartCounts   map[string]*memoryRestartCounts
+	restartHistory  map[string]*memoryRestartHistory
+}
+
+type expiringValue struct {
+	value     string
+	expiresAt time.Time
+}
+
+type memoryIncident struct {
+	// the events of the incident encoded as JSON, scored by the time they were added
+	events *sortedSet
+
+	// the pods and other objects affected by the incident which are not resolved yet
+	pods map[string]bool
+
+	// the IDs of the logs of the incident, scored by the time they were added
+	logIDs *sortedSet
+
+	// the highest severity of the events, and the severity set by the incident policy of the
+	// release on the latest event
+	severity       models.Severity
+	policySeverity models.Severity
+
+	expiresAt time.Time
+}
+
+type memoryRestartCounts struct {
+	counts    map[string]int32
+	expiresAt time.Time
+}
+
+type memoryRestartHistory struct {
+	samples   *sortedSet
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		pending:         &sortedSet{},
+		incidents:       make(map[string]*memoryIncident),
+		activeIncidents: make(map[string]*expiringValue),
+		logs:            make(map[string]*expiringValue),
+		restartCounts:   make(map[string]*memoryRestartCounts),
+		restartHistory:  make(map[string]*memoryRestartHistory),
+	}
+}
+
+func (s *MemoryStore) AppendToNotifyWorkQueue(ctx context.Context, packed []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending.add(string(packed), float64(time.Now().Unix()))
+
+	return nil
+}
+
+func (s *MemoryStore) GetItemFromPendingQueue(ctx context.Context) ([]byte, float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.pending.popMin()
+	if !ok {
+		return []byte{}, 0, porterErrors.NoPendingItemError
+	}
+
+	return []byte(item.member), item.score, nil
+}
+
+func (s *MemoryStore) RequeueItemWithScore(ctx context.Context, packed []byte, score float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending.add(string(packed), score)
+
+	return nil
+}
+
+func (s *MemoryStore) IsFirstRun(ctx context.Context) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.agentCreationTimestamp == 0, nil
+}
+
+func (s *MemoryStore) SetAgentCreationTimestamp(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.agentCreationTimestamp != 0 {
+		return fmt.Errorf("agent timestamp already exists in memory store")
+	}
+
+	s.agentCreationTimestamp = time.Now().Unix()
+
+	return nil
+}
+
+func (s *MemoryStore) GetAgentCreationTimestamp(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.agentCreationTimestamp == 0 {
+		s.agentCreationTimestamp = time.Now().Unix()
+	}
+
+	return s.agentCreationTimestamp, nil
+}
+
+func (s *MemoryStore) IncidentExists(ctx context.Context, incident string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.incidentExists(incident), nil
+}
+
+func (s *MemoryStore) GetLatestEventForIncident(ctx context.Context, incidentID string) (*models.PodEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.getLatestEvent(incidentID)
+}
+
+func (s *MemoryStore) AddEventToIncident(
+	ctx context.Context, incidentID string, event *models.PodEvent, newIncident bool,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isActiveIncident(incidentID) {
+		return fmt.Errorf("%w: incident ID: %s", porterErrors.IncidentNotActiveError, incidentID)
+	}
+
+	incident := s.getIncident(incidentID, true)
+
+	if !newIncident && incident.events.len() >= maxIncidentEvents {
+		return fmt.Errorf("%w of %d for incident ID: %s", porterErrors.MaxEventCountError, maxIncidentEvents, incidentID)
+	}
+
+	score := time.Now().Unix()
+
+	event.EventID = fmt.Sprintf("%s:%d", incidentID, score)
+
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error marshalling to JSON with event ID: %s. Error: %w", event.EventID, err)
+	}
+
+	incident.events.add(string(eventJSON), float64(score))
+	incident.pods[event.PodName] = true
+	incident.severity = models.MaxSeverity(incident.severity, utils.GetEventSeverity(event))
+	incident.policySeverity = event.PolicySeverity
+
+	if newIncident {
+		// we need to add this new incident to the pending queue so that it gets pushed out as a notification
+		s.pending.add("new:"+incidentID, float64(time.Now().Unix()))
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) SetPodResolved(ctx context.Context, podName, incidentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.incidentExists(incidentID) {
+		return fmt.Errorf("trying to set pod resolved for non-existent incident with ID: %s", incidentID)
+	}
+
+	incident := s.getIncident(incidentID, false)
+
+	if !incident.pods[podName] {
+		return nil
+	}
+
+	delete(incident.pods, podName)
+
+	if len(incident.pods) == 0 {
+		// all pods are now healthy, delete the active incident
+		s.resolveIncident(incidentID)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) SetJobIncidentResolved(ctx context.Context, incidentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.incidentExists(incidentID) {
+		return fmt.Errorf("trying to set job incident resolved for non-existent incident with ID: %s", incidentID)
+	}
+
+	incident := s.getIncident(incidentID, false)
+
+	// the incident was resolved before
+	if len(incident.pods) == 0 && !s.isActiveIncident(incidentID) {
+		return nil
+	}
+
+	incident.pods = make(map[string]bool)
+	s.resolveIncident(incidentID)
+
+	return nil
+}
+
+func (s *MemoryStore) GetIncidentDetails(ctx context.Context, incidentID string) (*models.Incident, error) {
+	return getIncidentDetails(ctx, s, incidentID)
+}
+
+func (s *MemoryStore) IsIncidentResolved(ctx context.Context, incidentID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.isIncidentResolved(incidentID), nil
+}
+
+func (s *MemoryStore) GetAllIncidents(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.listIncidents(func(*utils.Incident) bool { return true }), nil
+}
+
+func (s *MemoryStore) GetAllActiveIncidents(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpired()
+
+	var incidentIDs []string
+
+	for _, active := range s.activeIncidents {
+		incidentIDs = append(incidentIDs, active.value)
+	}
+
+	sort.Strings(incidentIDs)
+
+	return incidentIDs, nil
+}
+
+func (s *MemoryStore) GetPodsForIncident(ctx context.Context, incidentID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	incident := s.getIncident(incidentID, false)
+	if incident == nil {
+		return nil, nil
+	}
+
+	var pods []string
+
+	for pod := range incident.pods {
+		pods = append(pods, pod)
+	}
+
+	sort.Strings(pods)
+
+	return pods, nil
+}
+
+func (s *MemoryStore) GetIncidentsByReleaseNamespace(
+	ctx context.Context, releaseName, namespace string,
+) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.listIncidents(func(incidentObj *utils.Incident) bool {
+		return incidentObj.GetReleaseName() == releaseName && incidentObj.GetNamespace() == namespace
+	}), nil
+}
+
+func (s *MemoryStore) GetIncidentEventsByID(ctx context.Context, incidentID string) ([]*models.PodEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.getEvents(incidentID)
+}
+
+func (s *MemoryStore) GetIncidentSeverity(ctx context.Context, incidentID string) (models.Severity, models.Severity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	incident := s.getIncident(incidentID, false)
+	if incident == nil {
+		return "", "", nil
+	}
+
+	return incident.severity, incident.policySeverity, nil
+}
+
+func (s *MemoryStore) AddLogs(ctx context.Context, incidentID, strLogs string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	compressed, err := utils.CompressLogs(strLogs)
+	if err != nil {
+		return "", err
+	}
+
+	logID := utils.NewLogID(strLogs)
+	expiresAt := getLogExpiry(incidentID, time.Now())
+
+	// logs which are already stored are kept for as long as they are referenced
+	if logs, ok := s.logs[logID]; ok && logs.expiresAt.After(expiresAt) {
+		expiresAt = logs.expiresAt
+	}
+
+	s.logs[logID] = &expiringValue{
+		value:     string(compressed),
+		expiresAt: expiresAt,
+	}
+
+	s.getIncident(incidentID, true).logIDs.add(logID, float64(time.Now().Unix()))
+
+	return logID, nil
+}
+
+func (s *MemoryStore) GetLogs(ctx context.Context, logID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpired()
+
+	logs, ok := s.logs[logID]
+	if !ok {
+		return "", fmt.Errorf("no such logs with ID: %s", logID)
+	}
+
+	return utils.DecompressLogs([]byte(logs.value))
+}
+
+func (s *MemoryStore) GetActiveIncident(ctx context.Context, releaseName, namespace string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpired()
+
+	active, ok := s.activeIncidents[activeIncidentKey(releaseName, namespace)]
+	if !ok {
+		return "", fmt.Errorf("error fetching active incident for %s in namespace %s: no active incident",
+			releaseName, namespace)
+	}
+
+	return active.value, nil
+}
+
+func (s *MemoryStore) ActiveIncidentExists(ctx context.Context, releaseName, namespace string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpired()
+
+	_, ok := s.activeIncidents[activeIncidentKey(releaseName, namespace)]
+
+	return ok, nil
+}
+
+func (s *MemoryStore) GetOrCreateActiveIncident(ctx context.Context, releaseName, namespace string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpired()
+
+	key := activeIncidentKey(releaseName, namespace)
+
+	if active, ok := s.activeIncidents[key]; ok {
+		return active.value, false, nil
+	}
+
+	newIncident := utils.NewIncident(releaseName, namespace, time.Now().Unix())
+
+	s.activeIncidents[key] = &expiringValue{
+		value:     newIncident.ToString(),
+		expiresAt: time.Now().Add(incidentTTL),
+	}
+
+	return newIncident.ToString(), true, nil
+}
+
+func (s *MemoryStore) GetLastRestartCount(
+	ctx context.Context, releaseName, namespace, podName, containerName string,
+) (int32, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpired()
+
+	restartCounts, ok := s.restartCounts[activeIncidentKey(releaseName, namespace)]
+	if !ok {
+		return 0, false, nil
+	}
+
+	count, ok := restartCounts.counts[fmt.Sprintf("%s/%s", podName, containerName)]
+
+	return count, ok, nil
+}
+
+func (s *MemoryStore) AddRestartSample(
+	ctx context.Context, releaseName, namespace string, sample *models.RestartSample, window time.Duration,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpired()
+
+	key := activeIncidentKey(releaseName, namespace)
+
+	restartCounts, ok := s.restartCounts[key]
+	if !ok {
+		restartCounts = &memoryRestartCounts{counts: make(map[string]int32)}
+		s.restartCounts[key] = restartCounts
+	}
+
+	restartCounts.counts[fmt.Sprintf("%s/%s", sample.PodName, sample.ContainerName)] = sample.RestartCount
+	restartCounts.expiresAt = time.Now().Add(window)
+
+	if sample.Restarts <= 0 {
+		return nil
+	}
+
+	sampleJSON, err := json.Marshal(sample)
+	if err != nil {
+		return fmt.Errorf("error marshalling restart sample to JSON for pod %s. Error: %w", sample.PodName, err)
+	}
+
+	history, ok := s.restartHistory[key]
+	if !ok {
+		history = &memoryRestartHistory{samples: &sortedSet{}}
+		s.restartHistory[key] = history
+	}
+
+	history.samples.add(string(sampleJSON), float64(sample.Timestamp))
+	history.samples.removeBelow(float64(time.Now().Add(-window).Unix()))
+	history.expiresAt = time.Now().Add(window)
+
+	return nil
+}
+
+func (s *MemoryStore) GetRestartHistory(
+	ctx context.Context, releaseName, namespace string, since time.Time,
+) ([]*models.RestartSample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpired()
+
+	history, ok := s.restartHistory[activeIncidentKey(releaseName, namespace)]
+	if !ok {
+		return nil, nil
+	}
+
+	var samples []*models.RestartSample
+
+	for _, member := range history.samples.members {
+		if member.score < float64(since.Unix()) {
+			continue
+		}
+
+		sample := &models.RestartSample{}
+
+		if err := json.Unmarshal([]byte(member.member), sample); err != nil {
+			return nil, fmt.Errorf("error unmarshalling restart sample for release %s in namespace %s. Error: %w",
+				releaseName, namespace, err)
+		}
+
+		samples = append(samples, sample)
+	}
+
+	return samples, nil
+}
+
+// getIncident returns the incident with the ID, creating it if it does not exist and create is set.
+// The incident expires once the configured incident retention has passed since it was created.
+func (s *MemoryStore) getIncident(incidentID string, create bool) *memoryIncident {
+	s.purgeExpired()
+
+	incident, ok := s.incidents[incidentID]
+	if !ok && create {
+		incident = &memoryIncident{
+			events: &sortedSet{},
+			pods:   make(map[string]bool),
+			logIDs: &sortedSet{},
+		}
+
+		incident.expiresAt = getIncidentExpiry(incidentID)
+
+		s.incidents[incidentID] = incident
+	}
+
+	return incident
+}
+
+// isActiveIncident returns whether the incident is the active incident of its release
+func (s *MemoryStore) isActiveIncident(incidentID string) bool {
+	incidentObj, err := utils.NewIncidentFromString(incidentID)
+	if err != nil {
+		return false
+	}
+
+	s.purgeExpired()
+
+	active, ok := s.activeIncidents[activeIncidentKey(incidentObj.GetReleaseName(), incidentObj.GetNamespace())]
+
+	return ok && active.value == incidentID
+}
+
+// incidentExists returns whether an event was added to the incident, since the logs of an incident
+// may be added before its first event
+func (s *MemoryStore) incidentExists(incidentID string) bool {
+	incident := s.getIncident(incidentID, false)
+
+	return incident != nil && incident.events.len() > 0
+}
+
+func (s *MemoryStore) isIncidentResolved(incidentID string) bool {
+	incident := s.getIncident(incidentID, false)
+
+	return incident == nil || len(incident.pods) == 0
+}
+
+// resolveIncident removes the active incident of the release of the incident and queues the
+// notification of the resolved incident
+func (s *MemoryStore) resolveIncident(incidentID string) {
+	if incidentObj, err := utils.NewIncidentFromString(incidentID); err == nil {
+		key := activeIncidentKey(incidentObj.GetReleaseName(), incidentObj.GetNamespace())
+
+		// a newer incident of the release may be active by now
+		if active, ok := s.activeIncidents[key]; ok && active.value == incidentID {
+			delete(s.activeIncidents, key)
+		}
+	}
+
+	s.pending.add("resolved:"+incidentID, float64(time.Now().Unix()))
+}
+
+func (s *MemoryStore) getLatestEvent(incidentID string) (*models.PodEvent, error) {
+	incident := s.getIncident(incidentID, false)
+	if incident == nil || incident.events.len() == 0 {
+		// no latest event exists, possibly a new incident
+		return nil, nil
+	}
+
+	event := &models.PodEvent{}
+
+	if err := json.Unmarshal([]byte(incident.events.last().member), event); err != nil {
+		return nil, fmt.Errorf("error unmarshalling event to json for incident ID: %s. Error: %w", incidentID, err)
+	}
+
+	return event, nil
+}
+
+// getEvents returns the events of the incident, latest first
+func (s *MemoryStore) getEvents(incidentID string) ([]*models.PodEvent, error) {
+	incident := s.getIncident(incidentID, false)
+	if incident == nil {
+		return nil, nil
+	}
+
+	var events []*models.PodEvent
+
+	for i := incident.events.len() - 1; i >= 0; i-- {
+		event := &models.PodEvent{}
+
+		if err := json.Unmarshal([]byte(incident.events.members[i].member), event); err != nil {
+			return nil, fmt.Errorf("error unmarshalling event to json for incident ID: %s. Error: %w", incidentID, err)
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// listIncidents returns the IDs of the incidents which match the filter, latest first
+func (s *MemoryStore) listIncidents(filter func(*utils.Incident) bool) []string {
+	s.purgeExpired()
+
+	var incidents []string
+
+	for incidentID, incident := range s.incidents {
+		if incident.events.len() == 0 {
+			continue
+		}
+
+		if incidentObj, err := utils.NewIncidentFromString(incidentID); err == nil && filter(incidentObj) {
+			incidents = append(incidents, incidentID)
+		}
+	}
+
+	sortIncidents(incidents)
+
+	return incidents
+}
+
+// purgeExpired drops everything which has expired, same as Redis would
+func (s *MemoryStore) purgeExpired() {
+	now := time.Now()
+
+	for incidentID, incident := range s.incidents {
+		if isExpired(incident.expiresAt, now) {
+			delete(s.incidents, incidentID)
+		}
+	}
+
+	for _, values := range []map[string]*expiringValue{s.activeIncidents, s.logs} {
+		for key, value := range values {
+			if isExpired(value.expiresAt, now) {
+				delete(values, key)
+			}
+		}
+	}
+
+	for key, restartCounts := range s.restartCounts {
+		if isExpired(restartCounts.expiresAt, now) {
+			delete(s.restartCounts, key)
+		}
+	}
+
+	for key, history := range s.restartHistory {
+		if isExpired(history.expiresAt, now) {
+			delete(s.restartHistory, key)
+		}
+	}
+}
+
+// sortedSet is a set of members ordered by their score and then by the members themselves, like a
+// sorted set of Redis
+type sortedSet struct {
+	members []sortedSetMember
+}
+
+type sortedSetMember struct {
+	member string
+	score  float64
+}
+
+// add adds the member to the set, or updates its score if it is already in the set
+func (z *sortedSet) add(member string, score float64) {
+	for i := range z.members {
+		if z.members[i].member == member {
+			z.members = append(z.members[:i], z.members[i+1:]...)
+			break
+		}
+	}
+
+	i := sort.Search(len(z.members), func(i int) bool {
+		if z.members[i].score != score {
+			return z.members[i].score > score
+		}
+
+		return z.members[i].member > member
+	})
+
+	z.members = append(z.members, sortedSetMember{})
+	copy(z.members[i+1:], z.members[i:])
+	z.members[i] = sortedSetMember{member: member, score: score}
+}
+
+func (z *sortedSet) popMin() (sortedSetMember, bool) {
+	if len(z.members) == 0 {
+		return sortedSetMember{}, false
+	}
+
+	min := z.members[0]
+	z.members = z.members[1:]
+
+	return min, true
+}
+
+func (z *sortedSet) last() sortedSetMember {
+	return z.members[len(z.members)-1]
+}
+
+// removeBelow removes the members with a score lower than the given score
+func (z *sortedSet) removeBelow(score float64) {
+	i := sort.Search(len(z.members), func(i int) bool {
+		return z.members[i].score >= score
+	})
+
+	z.members = z.members[i:]
+}
+
+func (z *sortedSet) len() int {
+	return len(z.members)
+}
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/porter-dev/porter-agent/pkg/redis"
+	"github.com/porter-dev/porter-agent/pkg/utils"
+	bolt "go.etcd.io/bbolt"
+)
+
+// MigrateFromRedis copies the incidents of the Redis store at REDIS_HOST, along with their events,
+// pods and logs, the active incidents and the pending notifications into the bolt database at
+// BOLT_PATH. Incidents which already exist in the database are replaced, so the migration can be
+// run again. The restart history of releases is not copied, since it only covers recent restarts.
+func MigrateFromRedis(ctx context.Context) error {
+	from := newRedisClient()
+
+	if err := from.EnsureIndexes(ctx); err != nil {
+		return err
+	}
+
+	to, err := NewBoltStore(boltPath)
+	if err != nil {
+		return err
+	}
+	defer to.Close()
+
+	return to.importFromRedis(ctx, from)
+}
+
+func (s *BoltStore) importFromRedis(ctx context.Context, from *redis.Client) error {
+	agentCreationTimestamp, err := from.GetAgentCreationTimestamp(ctx)
+	if err != nil {
+		return fmt.Errorf("error fetching agent creation timestamp: %w", err)
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltMetaBucket).Put(boltAgentCreationTimestampKey,
+			[]byte(fmt.Sprintf("%d", agentCreationTimestamp)))
+	})
+	if err != nil {
+		return err
+	}
+
+	incidentIDs, err := from.GetAllIncidents(ctx)
+	if err != nil {
+		return fmt.Errorf("error fetching incidents: %w", err)
+	}
+
+	for _, incidentID := range incidentIDs {
+		if err := s.importIncidentFromRedis(ctx, from, incidentID); err != nil {
+			return fmt.Errorf("error migrating incident %s: %w", incidentID, err)
+		}
+	}
+
+	activeIncidentIDs, err := from.GetAllActiveIncidents(ctx)
+	if err != nil {
+		return fmt.Errorf("error fetching active incidents: %w", err)
+	}
+
+	pendingItems, err := from.GetPendingItems(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, incidentID := range activeIncidentIDs {
+			incidentObj, err := utils.NewIncidentFromString(incidentID)
+			if err != nil {
+				return err
+			}
+
+			err = tx.Bucket(boltActiveIncidentsBucket).Put(
+				[]byte(activeIncidentKey(incidentObj.GetReleaseName(), incidentObj.GetNamespace())),
+				encodeExpiring(getIncidentExpiry(incidentID), []byte(incidentID)))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, item := range pendingItems {
+			if err := putPendingItem(tx, item.Packed, item.Score); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *BoltStore) importIncidentFromRedis(ctx context.Context, from *redis.Client, incidentID string) error {
+	events, err := from.GetIncidentEventsByID(ctx, incidentID)
+	if err != nil {
+		return err
+	}
+
+	pods, err := from.GetPodsForIncident(ctx, incidentID)
+	if err != nil {
+		return err
+	}
+
+	logIDs, err := from.GetLogIDsForIncident(ctx, incidentID)
+	if err != nil {
+		return err
+	}
+
+	logs := make(map[string][]byte)
+
+	for _, logID := range logIDs {
+		// logs which have expired in the meantime are skipped
+		strLogs, err := from.GetLogs(ctx, logID.LogID)
+		if err != nil {
+			continue
+		}
+
+		if logs[logID.LogID], err = utils.CompressLogs(strLogs); err != nil {
+			return err
+		}
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltIncidentsBucket).Bucket([]byte(incidentID)) != nil {
+			if err := tx.Bucket(boltIncidentsBucket).DeleteBucket([]byte(incidentID)); err != nil {
+				return err
+			}
+		}
+
+		incident, err := createIncidentBucket(tx, incidentID)
+		if err != nil {
+			return err
+		}
+
+		// the events are listed latest first
+		for i := len(events) - 1; i >= 0; i-- {
+			eventJSON, err := json.Marshal(events[i])
+			if err != nil {
+				return err
+			}
+
+			score := getIDTimestamp(events[i].EventID)
+			if score == 0 {
+				score = events[i].Timestamp
+			}
+
+			if err := putScored(incident.Bucket(boltEventsBucket), score, eventJSON); err != nil {
+				return err
+			}
+		}
+
+		for _, pod := range pods {
+			if err := incident.Bucket(boltPodsBucket).Put([]byte(pod), []byte{}); err != nil {
+				return err
+			}
+		}
+
+		for _, logID := range logIDs {
+			compressed, ok := logs[logID.LogID]
+			if !ok {
+				continue
+			}
+
+			// logs from earlier versions of the agent keep their IDs, since events refer to them
+			expiresAt := getLogExpiry(incidentID, time.Unix(logID.Timestamp, 0))
+
+			if err := putBoltLogs(tx, incidentID, logID.LogID, compressed, logID.Timestamp, expiresAt); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
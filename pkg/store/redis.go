@@ -0,0 +1,18 @@
+package store
+
+import (
+	"context"
+
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/porter-dev/porter-agent/pkg/redis"
+)
+
+// RedisStore is the IncidentStore which keeps its data in Redis through the Redis client, with the
+// details of incidents put together the same way as for the other backends
+type RedisStore struct {
+	*redis.Client
+}
+
+func (s *RedisStore) GetIncidentDetails(ctx context.Context, incidentID string) (*models.Incident, error) {
+	return getIncidentDetails(ctx, s, incidentID)
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/porter-dev/porter-agent/pkg/redis"
+	"github.com/spf13/viper"
+)
+
+const (
+	RedisBackend  = "redis"
+	MemoryBackend = "memory"
+	BoltBackend   = "bolt"
+)
+
+var (
+	backend      string
+	boltPath     string
+	redisHost    string
+	redisPort    string
+	maxTailLines int64
+
+	// incidents and active incidents expire once their retention has passed since they were
+	// created, and logs once their retention has passed since they were added, but never before
+	// the incidents which refer to them
+	incidentTTL       time.Duration
+	logTTL            time.Duration
+	maxIncidentEvents int
+)
+
+func init() {
+	viper.SetDefault("STORE_BACKEND", RedisBackend)
+	viper.SetDefault("BOLT_PATH", "/var/lib/porter-agent/incidents.db")
+	viper.SetDefault("REDIS_HOST", "porter-redis-master")
+	viper.SetDefault("REDIS_PORT", "6379")
+	viper.SetDefault("MAX_TAIL_LINES", int64(100))
+	viper.SetDefault("INCIDENT_RETENTION", redis.DefaultIncidentTTL.String())
+	viper.SetDefault("LOG_RETENTION", redis.DefaultLogTTL.String())
+	viper.SetDefault("MAX_INCIDENT_EVENTS", redis.DefaultMaxIncidentEvents)
+	viper.AutomaticEnv()
+
+	backend = viper.GetString("STORE_BACKEND")
+	boltPath = viper.GetString("BOLT_PATH")
+	redisHost = viper.GetString("REDIS_HOST")
+	redisPort = viper.GetString("REDIS_PORT")
+	maxTailLines = viper.GetInt64("MAX_TAIL_LINES")
+	incidentTTL = viper.GetDuration("INCIDENT_RETENTION")
+	logTTL = viper.GetDuration("LOG_RETENTION")
+	maxIncidentEvents = viper.GetInt("MAX_INCIDENT_EVENTS")
+
+	if backend != RedisBackend && backend != MemoryBackend && backend != BoltBackend {
+		panic(fmt.Sprintf("invalid STORE_BACKEND %q, must be one of %s, %s or %s", backend, RedisBackend,
+			MemoryBackend, BoltBackend))
+	}
+
+	if incidentTTL <= 0 || logTTL <= 0 {
+		panic(fmt.Sprintf("invalid INCIDENT_RETENTION %q or LOG_RETENTION %q, must be positive durations",
+			viper.GetString("INCIDENT_RETENTION"), viper.GetString("LOG_RETENTION")))
+	}
+
+	if maxIncidentEvents <= 0 {
+		panic(fmt.Sprintf("invalid MAX_INCIDENT_EVENTS %d, must be positive", maxIncidentEvents))
+	}
+}
+
+// IncidentStore stores the incidents of releases along with their events, affected pods and logs,
+// and the queue of pending notifications for new and resolved incidents
+type IncidentStore interface {
+	AppendToNotifyWorkQueue(ctx context.Context, packed []byte) error
+	GetItemFromPendingQueue(ctx context.Context) ([]byte, float64, error)
+	RequeueItemWithScore(ctx context.Context, packed []byte, score float64) error
+
+	IsFirstRun(ctx context.Context) (bool, error)
+	SetAgentCreationTimestamp(ctx context.Context) error
+	GetAgentCreationTimestamp(ctx context.Context) (int64, error)
+
+	IncidentExists(ctx context.Context, incident string) (bool, error)
+	GetLatestEventForIncident(ctx context.Context, incidentID string) (*models.PodEvent, error)
+	AddEventToIncident(ctx context.Context, incidentID string, event *models.PodEvent, newIncident bool) error
+	SetPodResolved(ctx context.Context, podName, incidentID string) error
+	SetJobIncidentResolved(ctx context.Context, incidentID string) error
+	GetIncidentDetails(ctx context.Context, incidentID string) (*models.Incident, error)
+	IsIncidentResolved(ctx context.Context, incidentID string) (bool, error)
+	GetAllIncidents(ctx context.Context) ([]string, error)
+	GetAllActiveIncidents(ctx context.Context) ([]string, error)
+	GetPodsForIncident(ctx context.Context, incidentID string) ([]string, error)
+	GetIncidentsByReleaseNamespace(ctx context.Context, releaseName, namespace string) ([]string, error)
+	GetIncidentEventsByID(ctx context.Context, incidentID string) ([]*models.PodEvent, error)
+	GetIncidentSeverity(ctx context.Context, incidentID string) (models.Severity, models.Severity, error)
+
+	AddLogs(ctx context.Context, incidentID, strLogs string) (string, error)
+	GetLogs(ctx context.Context, logID string) (string, error)
+
+	GetActiveIncident(ctx context.Context, releaseName, namespace string) (string, error)
+	ActiveIncidentExists(ctx context.Context, releaseName, namespace string) (bool, error)
+	GetOrCreateActiveIncident(ctx context.Context, releaseName, namespace string) (string, bool, error)
+
+	GetLastRestartCount(ctx context.Context, releaseName, namespace, podName, containerName string) (int32, bool, error)
+	AddRestartSample(ctx context.Context, releaseName, namespace string, sample *models.RestartSample, window time.Duration) error
+	GetRestartHistory(ctx context.Context, releaseName, namespace string, since time.Time) ([]*models.RestartSample, error)
+}
+
+var (
+	_ IncidentStore = &RedisStore{}
+	_ IncidentStore = &MemoryStore{}
+	_ IncidentStore = &BoltStore{}
+)
+
+// GetBackend returns the backend of the incident store set in STORE_BACKEND
+func GetBackend() string {
+	return backend
+}
+
+// NewIncidentStore returns the incident store of the backend set in STORE_BACKEND. The store is
+// meant to be shared by the whole agent, since the memory backend only lives in this process and
+// the database of the bolt backend can only be opened once.
+func NewIncidentStore() (IncidentStore, error) {
+	switch backend {
+	case MemoryBackend:
+		return NewMemoryStore(), nil
+	case BoltBackend:
+		boltStore, err := NewBoltStore(boltPath)
+		if err != nil {
+			return nil, err
+		}
+
+		go boltStore.PurgeExpiredPeriodically(context.Background())
+
+		return boltStore, nil
+	}
+
+	redisClient := newRedisClient()
+
+	// incidents stored by earlier versions of the agent are not in the indexes yet
+	if err := redisClient.EnsureIndexes(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return &RedisStore{Client: redisClient}, nil
+}
+
+func newRedisClient() *redis.Client {
+	redisClient := redis.NewClient(redisHost, redisPort, "", "", redis.PODSTORE, maxTailLines)
+	redisClient.SetRetention(incidentTTL, logTTL, int64(maxIncidentEvents))
+
+	return redisClient
+}
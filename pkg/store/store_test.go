@@ -0,0 +1,544 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	porterErrors "github.com/porter-dev/porter-agent/pkg/errors"
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/porter-dev/porter-agent/pkg/redis"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	testReleaseName = "web"
+	testNamespace   = "default"
+
+	// the number of goroutines racing for each transition
+	testConcurrency = 20
+)
+
+// forEachBackend runs the test against a new store of every backend
+func forEachBackend(t *testing.T, test func(t *testing.T, incidentStore IncidentStore)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+
+	t.Run("bolt", func(t *testing.T) {
+		boltStore, err := NewBoltStore(t.TempDir() + "/incidents.db")
+		if err != nil {
+			t.Fatalf("error opening bolt store: %v", err)
+		}
+
+		t.Cleanup(func() {
+			boltStore.Close()
+		})
+
+		test(t, boltStore)
+	})
+
+	t.Run("redis", func(t *testing.T) {
+		server := miniredis.RunT(t)
+
+		test(t, &RedisStore{Client: redis.NewClient(server.Host(), server.Port(), "", "", redis.PODSTORE, maxTailLines)})
+	})
+}
+
+func newTestEvent(podName string) *models.PodEvent {
+	return &models.PodEvent{
+		PodName:   podName,
+		Namespace: testNamespace,
+		OwnerName: testReleaseName,
+		Timestamp: time.Now().Unix(),
+		Reason:    "Error",
+		Message:   "The application exited with a non-zero exit code.",
+	}
+}
+
+// drainPendingQueue returns the number of new and resolved notifications in the pending queue
+func drainPendingQueue(t *testing.T, incidentStore IncidentStore) map[string]int {
+	counts := make(map[string]int)
+
+	for {
+		item, _, err := incidentStore.GetItemFromPendingQueue(context.Background())
+		if errors.Is(err, porterErrors.NoPendingItemError) {
+			return counts
+		} else if err != nil {
+			t.Fatalf("error reading pending queue: %v", err)
+		}
+
+		counts[strings.SplitN(string(item), ":", 2)[0]]++
+	}
+}
+
+// addEventToActiveIncident adds the event like the controllers do, moving on to a new incident
+// if the incident was resolved in the meantime
+func addEventToActiveIncident(ctx context.Context, incidentStore IncidentStore, releaseName string, event *models.PodEvent) error {
+	for {
+		incidentID, newIncident, err := incidentStore.GetOrCreateActiveIncident(ctx, releaseName, testNamespace)
+		if err != nil {
+			return err
+		}
+
+		err = incidentStore.AddEventToIncident(ctx, incidentID, event, newIncident)
+		if !errors.Is(err, porterErrors.IncidentNotActiveError) {
+			return err
+		}
+	}
+}
+
+func TestGetOrCreateActiveIncidentConcurrently(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, incidentStore IncidentStore) {
+		ctx := context.Background()
+
+		var (
+			wg          sync.WaitGroup
+			mu          sync.Mutex
+			created     int
+			incidentIDs = make(map[string]bool)
+		)
+
+		for i := 0; i < testConcurrency; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				incidentID, newIncident, err := incidentStore.GetOrCreateActiveIncident(ctx, testReleaseName, testNamespace)
+				if err != nil {
+					t.Errorf("error creating active incident: %v", err)
+					return
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				incidentIDs[incidentID] = true
+
+				if newIncident {
+					created++
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		if created != 1 {
+			t.Errorf("expected exactly one incident to be created, got %d", created)
+		}
+
+		if len(incidentIDs) != 1 {
+			t.Errorf("expected every caller to get the same incident, got %v", incidentIDs)
+		}
+	})
+}
+
+func TestAddEventToIncidentConcurrently(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, incidentStore IncidentStore) {
+		ctx := context.Background()
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < testConcurrency; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				if err := addEventToActiveIncident(ctx, incidentStore, testReleaseName, newTestEvent(fmt.Sprintf("web-%d", i))); err != nil {
+					t.Errorf("error adding event: %v", err)
+				}
+			}(i)
+		}
+
+		wg.Wait()
+
+		incidentIDs, err := incidentStore.GetAllIncidents(ctx)
+		if err != nil {
+			t.Fatalf("error listing incidents: %v", err)
+		}
+
+		if len(incidentIDs) != 1 {
+			t.Fatalf("expected exactly one incident, got %v", incidentIDs)
+		}
+
+		pods, err := incidentStore.GetPodsForIncident(ctx, incidentIDs[0])
+		if err != nil {
+			t.Fatalf("error listing pods of incident: %v", err)
+		}
+
+		if len(pods) != testConcurrency {
+			t.Errorf("expected %d pods in the incident, got %d", testConcurrency, len(pods))
+		}
+
+		if counts := drainPendingQueue(t, incidentStore); counts["new"] != 1 {
+			t.Errorf("expected exactly one new incident notification, got %d", counts["new"])
+		}
+	})
+}
+
+func TestSetPodResolvedConcurrently(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, incidentStore IncidentStore) {
+		ctx := context.Background()
+
+		for i := 0; i < testConcurrency; i++ {
+			if err := addEventToActiveIncident(ctx, incidentStore, testReleaseName, newTestEvent(fmt.Sprintf("web-%d", i))); err != nil {
+				t.Fatalf("error adding event: %v", err)
+			}
+		}
+
+		incidentID, err := incidentStore.GetActiveIncident(ctx, testReleaseName, testNamespace)
+		if err != nil {
+			t.Fatalf("error fetching active incident: %v", err)
+		}
+
+		drainPendingQueue(t, incidentStore)
+
+		var wg sync.WaitGroup
+
+		// every pod is resolved twice, like concurrent reconciles of the same pod would
+		for i := 0; i < 2*testConcurrency; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				if err := incidentStore.SetPodResolved(ctx, fmt.Sprintf("web-%d", i%testConcurrency), incidentID); err != nil {
+					t.Errorf("error resolving pod: %v", err)
+				}
+			}(i)
+		}
+
+		wg.Wait()
+
+		assertIncidentResolved(t, incidentStore, incidentID)
+	})
+}
+
+func TestSetJobIncidentResolvedConcurrently(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, incidentStore IncidentStore) {
+		ctx := context.Background()
+
+		if err := addEventToActiveIncident(ctx, incidentStore, testReleaseName, newTestEvent("job-0")); err != nil {
+			t.Fatalf("error adding event: %v", err)
+		}
+
+		incidentID, err := incidentStore.GetActiveIncident(ctx, testReleaseName, testNamespace)
+		if err != nil {
+			t.Fatalf("error fetching active incident: %v", err)
+		}
+
+		drainPendingQueue(t, incidentStore)
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < testConcurrency; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				if err := incidentStore.SetJobIncidentResolved(ctx, incidentID); err != nil {
+					t.Errorf("error resolving job incident: %v", err)
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		assertIncidentResolved(t, incidentStore, incidentID)
+	})
+}
+
+func TestAddEventToResolvedIncident(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, incidentStore IncidentStore) {
+		ctx := context.Background()
+
+		if err := addEventToActiveIncident(ctx, incidentStore, testReleaseName, newTestEvent("web-0")); err != nil {
+			t.Fatalf("error adding event: %v", err)
+		}
+
+		incidentID, err := incidentStore.GetActiveIncident(ctx, testReleaseName, testNamespace)
+		if err != nil {
+			t.Fatalf("error fetching active incident: %v", err)
+		}
+
+		drainPendingQueue(t, incidentStore)
+
+		if err := incidentStore.SetPodResolved(ctx, "web-0", incidentID); err != nil {
+			t.Fatalf("error resolving pod: %v", err)
+		}
+
+		err = incidentStore.AddEventToIncident(ctx, incidentID, newTestEvent("web-1"), false)
+		if !errors.Is(err, porterErrors.IncidentNotActiveError) {
+			t.Fatalf("expected adding an event to a resolved incident to fail with %v, got %v", porterErrors.IncidentNotActiveError, err)
+		}
+
+		assertIncidentResolved(t, incidentStore, incidentID)
+	})
+}
+
+// TestResolutionRacingEvents resolves incidents while events for other pods of the release are
+// added to them, which must either land in the incident before it is resolved or in a new incident
+func TestResolutionRacingEvents(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, incidentStore IncidentStore) {
+		ctx := context.Background()
+
+		for round := 0; round < 10; round++ {
+			releaseName := fmt.Sprintf("%s-%d", testReleaseName, round)
+
+			incidentID, newIncident, err := incidentStore.GetOrCreateActiveIncident(ctx, releaseName, testNamespace)
+			if err != nil {
+				t.Fatalf("error creating active incident: %v", err)
+			}
+
+			if err := incidentStore.AddEventToIncident(ctx, incidentID, newTestEvent("web-0"), newIncident); err != nil {
+				t.Fatalf("error adding event: %v", err)
+			}
+
+			var wg sync.WaitGroup
+
+			start := make(chan struct{})
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				<-start
+
+				if err := incidentStore.SetPodResolved(ctx, "web-0", incidentID); err != nil {
+					t.Errorf("error resolving pod: %v", err)
+				}
+			}()
+
+			for i := 1; i < testConcurrency; i++ {
+				wg.Add(1)
+
+				go func(i int) {
+					defer wg.Done()
+
+					<-start
+
+					err := incidentStore.AddEventToIncident(ctx, incidentID, newTestEvent(fmt.Sprintf("web-%d", i)), false)
+					if err != nil && !errors.Is(err, porterErrors.IncidentNotActiveError) {
+						t.Errorf("error adding event: %v", err)
+					}
+				}(i)
+			}
+
+			close(start)
+			wg.Wait()
+
+			resolved, err := incidentStore.IsIncidentResolved(ctx, incidentID)
+			if err != nil {
+				t.Fatalf("error checking if incident is resolved: %v", err)
+			}
+
+			activeIncidentID, _ := incidentStore.GetActiveIncident(ctx, releaseName, testNamespace)
+
+			// an ongoing incident which is not active can never be resolved
+			if resolved == (activeIncidentID == incidentID) {
+				t.Fatalf("incident %s is resolved: %t, but active incident is %q", incidentID, resolved, activeIncidentID)
+			}
+		}
+	})
+}
+
+func assertIncidentResolved(t *testing.T, incidentStore IncidentStore, incidentID string) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	resolved, err := incidentStore.IsIncidentResolved(ctx, incidentID)
+	if err != nil {
+		t.Fatalf("error checking if incident is resolved: %v", err)
+	}
+
+	if !resolved {
+		t.Errorf("expected incident %s to be resolved", incidentID)
+	}
+
+	active, err := incidentStore.ActiveIncidentExists(ctx, testReleaseName, testNamespace)
+	if err != nil {
+		t.Fatalf("error checking for active incident: %v", err)
+	}
+
+	if active {
+		t.Errorf("expected no active incident after incident %s was resolved", incidentID)
+	}
+
+	if counts := drainPendingQueue(t, incidentStore); counts["resolved"] != 1 {
+		t.Errorf("expected exactly one resolved incident notification, got %d", counts["resolved"])
+	}
+}
+
+func TestIncidentSeverity(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, incidentStore IncidentStore) {
+		ctx := context.Background()
+
+		events := []*models.PodEvent{
+			{Severity: models.SeverityLow},
+			{Severity: models.SeverityHigh},
+			{Severity: models.SeverityMedium, PolicySeverity: models.SeverityCritical},
+			{Severity: models.SeverityLow},
+		}
+
+		expected := []struct {
+			severity       models.Severity
+			policySeverity models.Severity
+		}{
+			{models.SeverityLow, ""},
+			{models.SeverityHigh, ""},
+			{models.SeverityHigh, models.SeverityCritical},
+			{models.SeverityHigh, ""},
+		}
+
+		for i, event := range events {
+			testEvent := newTestEvent("web-0")
+			testEvent.Severity = event.Severity
+			testEvent.PolicySeverity = event.PolicySeverity
+
+			if err := addEventToActiveIncident(ctx, incidentStore, testReleaseName, testEvent); err != nil {
+				t.Fatalf("error adding event: %v", err)
+			}
+
+			incidentID, err := incidentStore.GetActiveIncident(ctx, testReleaseName, testNamespace)
+			if err != nil {
+				t.Fatalf("error fetching active incident: %v", err)
+			}
+
+			severity, policySeverity, err := incidentStore.GetIncidentSeverity(ctx, incidentID)
+			if err != nil {
+				t.Fatalf("error fetching severity: %v", err)
+			}
+
+			if severity != expected[i].severity || policySeverity != expected[i].policySeverity {
+				t.Errorf("expected severity %q and policy severity %q after event %d, got %q and %q",
+					expected[i].severity, expected[i].policySeverity, i, severity, policySeverity)
+			}
+		}
+	})
+}
+
+func TestLogsKeptForIncidentRetention(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	redisClient := redis.NewClient(server.Host(), server.Port(), "", "", redis.PODSTORE, maxTailLines)
+	redisClient.SetRetention(48*time.Hour, time.Hour, int64(maxIncidentEvents))
+
+	incidentStore := &RedisStore{Client: redisClient}
+	ctx := context.Background()
+
+	incidentID, _, err := incidentStore.GetOrCreateActiveIncident(ctx, testReleaseName, testNamespace)
+	if err != nil {
+		t.Fatalf("error creating incident: %v", err)
+	}
+
+	logID, err := incidentStore.AddLogs(ctx, incidentID, "panic: runtime error")
+	if err != nil {
+		t.Fatalf("error adding logs: %v", err)
+	}
+
+	if ttl := server.TTL(logID); ttl < 47*time.Hour {
+		t.Errorf("expected logs to be kept for the retention of the incident, got TTL of %s", ttl)
+	}
+
+	server.SetTTL(logID, 72*time.Hour)
+
+	if _, err := incidentStore.AddLogs(ctx, incidentID, "panic: runtime error"); err != nil {
+		t.Fatalf("error adding logs: %v", err)
+	}
+
+	if ttl := server.TTL(logID); ttl != 72*time.Hour {
+		t.Errorf("expected adding the same logs to keep their TTL of %s, got %s", 72*time.Hour, ttl)
+	}
+}
+
+func TestPendingQueueOrder(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, incidentStore IncidentStore) {
+		ctx := context.Background()
+
+		for item, score := range map[string]float64{"b": 20, "a": 20, "c": 10, "d": 30} {
+			if err := incidentStore.RequeueItemWithScore(ctx, []byte(item), score); err != nil {
+				t.Fatalf("error queueing item: %v", err)
+			}
+		}
+
+		// requeueing an item moves it instead of queueing it twice
+		if err := incidentStore.RequeueItemWithScore(ctx, []byte("c"), 40); err != nil {
+			t.Fatalf("error requeueing item: %v", err)
+		}
+
+		assertPendingQueue(t, incidentStore, []string{"a", "b", "d", "c"}, []float64{20, 20, 30, 40})
+	})
+}
+
+func TestBoltPendingQueueOfEarlierVersion(t *testing.T) {
+	path := t.TempDir() + "/incidents.db"
+
+	boltStore, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("error opening bolt store: %v", err)
+	}
+
+	// earlier versions of the agent only kept the scores of the pending items
+	err = boltStore.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(boltPendingQueueBucket); err != nil {
+			return err
+		}
+
+		for item, score := range map[string]float64{"new:b": 20, "new:a": 10} {
+			value := make([]byte, 8)
+			binary.BigEndian.PutUint64(value, math.Float64bits(score))
+
+			if err := tx.Bucket(boltPendingBucket).Put([]byte(item), value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error writing pending items: %v", err)
+	}
+
+	boltStore.Close()
+
+	boltStore, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("error reopening bolt store: %v", err)
+	}
+
+	t.Cleanup(func() {
+		boltStore.Close()
+	})
+
+	assertPendingQueue(t, boltStore, []string{"new:a", "new:b"}, []float64{10, 20})
+}
+
+// assertPendingQueue drains the pending queue, which must hold the items with the scores in order
+func assertPendingQueue(t *testing.T, incidentStore IncidentStore, expectedItems []string, expectedScores []float64) {
+	for i := range expectedItems {
+		item, score, err := incidentStore.GetItemFromPendingQueue(context.Background())
+		if err != nil {
+			t.Fatalf("error reading pending queue: %v", err)
+		}
+
+		if string(item) != expectedItems[i] || score != expectedScores[i] {
+			t.Errorf("expected item %s with score %f, got %s with score %f", expectedItems[i], expectedScores[i], item, score)
+		}
+	}
+
+	if _, _, err := incidentStore.GetItemFromPendingQueue(context.Background()); !errors.Is(err, porterErrors.NoPendingItemError) {
+		t.Errorf("expected the pending queue to be empty, got %v", err)
+	}
+}
@@ -0,0 +1,417 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/porter-dev/porter-agent/pkg/models"
+)
+
+const (
+	// the number of frames kept in a crash signature
+	maxCrashFrames = 5
+
+	// the length of the crash message in the description of a crash signature
+	maxCrashMessageLength = 300
+)
+
+var crashLanguageNames = map[string]string{
+	"go":     "Go",
+	"python": "Python",
+	"java":   "Java",
+	"kotlin": "Kotlin",
+	"node":   "Node.js",
+	"ruby":   "Ruby",
+}
+
+// crashParser returns the last crash found in the lines of logs along with the line it starts at,
+// or nil if there is none
+type crashParser func(lines []string) (*models.CrashSignature, int)
+
+var crashParsers = []crashParser{
+	parseGoPanic,
+	parsePythonTraceback,
+	parseJavaException,
+	parseNodeError,
+	parseRubyBacktrace,
+}
+
+// ExtractCrashSignature returns the last Go panic, Python traceback, Java or Kotlin exception,
+// Node.js uncaught error or Ruby backtrace found in the logs, or nil if there is none
+func ExtractCrashSignature(logs string) *models.CrashSignature {
+	lines := strings.Split(strings.ReplaceAll(logs, "\r\n", "\n"), "\n")
+
+	var res *models.CrashSignature
+	start := -1
+
+	for _, parse := range crashParsers {
+		if signature, i := parse(lines); signature != nil && i > start {
+			res = signature
+			start = i
+		}
+	}
+
+	return res
+}
+
+// DescribeCrashSignature returns a sentence describing the crash, which is added to the details of
+// the incident
+func DescribeCrashSignature(signature *models.CrashSignature) string {
+	res := fmt.Sprintf("The application crashed with %s", signature.Type)
+
+	if name, ok := crashLanguageNames[signature.Language]; ok {
+		res += fmt.Sprintf(" (%s)", name)
+	}
+
+	if signature.Message != "" {
+		message := signature.Message
+
+		if len(message) > maxCrashMessageLength {
+			message = message[:maxCrashMessageLength] + "..."
+		}
+
+		res += ": " + message
+	}
+
+	if len(signature.Frames) > 0 {
+		res += ", at " + signature.Frames[0]
+	}
+
+	return res + "."
+}
+
+// GetEventSummary returns the message of the event followed by the description of the crash its
+// containers crashed with, if any
+func GetEventSummary(event *models.PodEvent) string {
+	containerNames := make([]string, 0, len(event.ContainerEvents))
+
+	for containerName := range event.ContainerEvents {
+		containerNames = append(containerNames, containerName)
+	}
+
+	sort.Strings(containerNames)
+
+	for _, containerName := range containerNames {
+		if signature := event.ContainerEvents[containerName].CrashSignature; signature != nil {
+			return strings.TrimSpace(event.Message + " " + DescribeCrashSignature(signature))
+		}
+	}
+
+	return event.Message
+}
+
+func appendCrashFrame(frames []string, frame string) []string {
+	if len(frames) >= maxCrashFrames {
+		return frames
+	}
+
+	return append(frames, frame)
+}
+
+var (
+	goPanicPattern     = regexp.MustCompile(`^(panic|fatal error): (.*)$`)
+	goGoroutinePattern = regexp.MustCompile(`^goroutine \d+ \[.*\]:$`)
+	goFuncArgsPattern  = regexp.MustCompile(`^(.+)\([^()]*\)$`)
+	goFileOffset       = regexp.MustCompile(` \+0x[0-9a-f]+$`)
+)
+
+// parseGoPanic parses the panic along with the stack of the goroutine which panicked:
+//
+//	panic: runtime error: index out of range [3] with length 3
+//
+//	goroutine 1 [running]:
+//	main.handler(...)
+//		/app/main.go:12 +0x1d
+func parseGoPanic(lines []string) (*models.CrashSignature, int) {
+	start := -1
+
+	for i := len(lines) - 1; i >= 0; i-- {
+		if goPanicPattern.MatchString(lines[i]) {
+			start = i
+			break
+		}
+	}
+
+	if start == -1 {
+		return nil, -1
+	}
+
+	match := goPanicPattern.FindStringSubmatch(lines[start])
+
+	signature := &models.CrashSignature{
+		Language: "go",
+		Type:     match[1],
+		Message:  strings.TrimSuffix(match[2], " [recovered]"),
+	}
+
+	if strings.HasPrefix(signature.Message, "runtime error: ") {
+		signature.Type = "runtime error"
+		signature.Message = strings.TrimPrefix(signature.Message, "runtime error: ")
+	}
+
+	i := start + 1
+
+	for i < len(lines) && !goGoroutinePattern.MatchString(lines[i]) {
+		i++
+	}
+
+	// the frames alternate between the function and its file, until the end of the goroutine
+	for i++; i+1 < len(lines) && strings.TrimSpace(lines[i]) != ""; i += 2 {
+		function := lines[i]
+
+		if strings.HasPrefix(function, "created by ") {
+			break
+		}
+
+		if match := goFuncArgsPattern.FindStringSubmatch(function); match != nil {
+			function = match[1]
+		}
+
+		if function == "panic" || strings.HasPrefix(function, "runtime.") {
+			continue
+		}
+
+		file := goFileOffset.ReplaceAllString(strings.TrimSpace(lines[i+1]), "")
+
+		signature.Frames = appendCrashFrame(signature.Frames, fmt.Sprintf("%s (%s)", function, file))
+	}
+
+	return signature, start
+}
+
+var (
+	pythonTracebackPattern = regexp.MustCompile(`^Traceback \(most recent call last\):$`)
+	pythonFramePattern     = regexp.MustCompile(`^\s+File "(.+)", line (\d+), in (.+)$`)
+	pythonExceptionPattern = regexp.MustCompile(`^([A-Za-z_][\w.]*)(?:: (.*))?$`)
+)
+
+// parsePythonTraceback parses the last traceback, which is the one raised last when exceptions are
+// chained:
+//
+//	Traceback (most recent call last):
+//	  File "/app/app.py", line 6, in main
+//	    1 / 0
+//	ZeroDivisionError: division by zero
+func parsePythonTraceback(lines []string) (*models.CrashSignature, int) {
+	start := -1
+
+	for i := len(lines) - 1; i >= 0; i-- {
+		if pythonTracebackPattern.MatchString(strings.TrimSpace(lines[i])) {
+			start = i
+			break
+		}
+	}
+
+	if start == -1 {
+		return nil, -1
+	}
+
+	var frames []string
+
+	for _, line := range lines[start+1:] {
+		if match := pythonFramePattern.FindStringSubmatch(line); match != nil {
+			frames = append(frames, fmt.Sprintf("%s (%s:%s)", match[3], match[1], match[2]))
+			continue
+		}
+
+		// the source of a frame and the markers below it are indented
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+
+		match := pythonExceptionPattern.FindStringSubmatch(line)
+		if match == nil {
+			return nil, -1
+		}
+
+		signature := &models.CrashSignature{
+			Language: "python",
+			Type:     match[1],
+			Message:  match[2],
+		}
+
+		// the most recent call is listed last
+		for i := len(frames) - 1; i >= 0; i-- {
+			signature.Frames = appendCrashFrame(signature.Frames, frames[i])
+		}
+
+		return signature, start
+	}
+
+	return nil, -1
+}
+
+var (
+	javaExceptionPattern = regexp.MustCompile(
+		`^(Caused by: |Exception in thread "[^"]*" )?((?:[a-zA-Z_$][\w$]*\.)+[a-zA-Z_$][\w$]*(?:Exception|Error|Throwable)[\w$]*)(?:: (.*))?$`)
+	javaFramePattern = regexp.MustCompile(`^\s+at ([\w$.<>/]+)\(([^)]*)\)$`)
+
+	// the frames of the runtime do not help to find the crash
+	javaRuntimeFramePrefixes = []string{"java.", "javax.", "jdk.", "sun.", "kotlin.", "kotlinx.coroutines."}
+)
+
+func isJavaRuntimeFrame(method string) bool {
+	// frames of the modules of the JDK are prefixed with their module, like "java.base/"
+	if i := strings.Index(method, "/"); i != -1 {
+		method = method[i+1:]
+	}
+
+	for _, prefix := range javaRuntimeFramePrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseJavaException parses the last exception which has a stack trace, and reports the innermost
+// cause it was caused by:
+//
+//	Exception in thread "main" java.lang.IllegalStateException: boom
+//		at com.example.App.main(App.java:5)
+//	Caused by: java.io.IOException: connection refused
+//		at com.example.Db.connect(Db.kt:12)
+//		... 1 more
+func parseJavaException(lines []string) (*models.CrashSignature, int) {
+	var signature *models.CrashSignature
+	start := -1
+
+	for i := 0; i < len(lines)-1; i++ {
+		match := javaExceptionPattern.FindStringSubmatch(strings.TrimRight(lines[i], " "))
+		if match == nil || !javaFramePattern.MatchString(lines[i+1]) {
+			continue
+		}
+
+		header := i
+
+		exception := &models.CrashSignature{
+			Language: "java",
+			Type:     match[2],
+			Message:  match[3],
+		}
+
+		for i++; i < len(lines) && javaFramePattern.MatchString(lines[i]); i++ {
+			frame := javaFramePattern.FindStringSubmatch(lines[i])
+
+			if strings.Contains(frame[2], ".kt:") {
+				exception.Language = "kotlin"
+			}
+
+			if isJavaRuntimeFrame(frame[1]) {
+				continue
+			}
+
+			exception.Frames = appendCrashFrame(exception.Frames, fmt.Sprintf("%s (%s)", frame[1], frame[2]))
+		}
+
+		i--
+
+		if match[1] == "Caused by: " && signature != nil {
+			// the cause is more specific than the exception it was wrapped in
+			if len(exception.Frames) == 0 {
+				exception.Frames = signature.Frames
+			}
+
+			if signature.Language == "kotlin" {
+				exception.Language = "kotlin"
+			}
+
+			signature = exception
+
+			continue
+		}
+
+		signature = exception
+		start = header
+	}
+
+	return signature, start
+}
+
+var (
+	nodeErrorPattern = regexp.MustCompile(`^(?:Uncaught )?([A-Z]\w*(?:Error|Exception)|Error)(?: \[\w+\])?: (.*)$`)
+	nodeFramePattern = regexp.MustCompile(`^\s+at (?:(.+?) \()?(.+?:\d+:\d+)\)?$`)
+)
+
+// parseNodeError parses the last error which has a stack trace:
+//
+//	TypeError: Cannot read properties of undefined (reading 'id')
+//	    at getUser (/app/index.js:10:21)
+//	    at Module._compile (node:internal/modules/cjs/loader:1105:14)
+func parseNodeError(lines []string) (*models.CrashSignature, int) {
+	for start := len(lines) - 2; start >= 0; start-- {
+		match := nodeErrorPattern.FindStringSubmatch(strings.TrimSpace(lines[start]))
+		if match == nil || !nodeFramePattern.MatchString(lines[start+1]) {
+			continue
+		}
+
+		signature := &models.CrashSignature{
+			Language: "node",
+			Type:     match[1],
+			Message:  match[2],
+		}
+
+		for _, line := range lines[start+1:] {
+			frame := nodeFramePattern.FindStringSubmatch(line)
+			if frame == nil {
+				break
+			}
+
+			// the frames of node itself do not help to find the crash
+			if strings.HasPrefix(frame[2], "node:") || strings.HasPrefix(frame[2], "internal/") {
+				continue
+			}
+
+			if frame[1] == "" {
+				signature.Frames = appendCrashFrame(signature.Frames, frame[2])
+			} else {
+				signature.Frames = appendCrashFrame(signature.Frames, fmt.Sprintf("%s (%s)", frame[1], frame[2]))
+			}
+		}
+
+		return signature, start
+	}
+
+	return nil, -1
+}
+
+var (
+	rubyErrorPattern = regexp.MustCompile("^(.+?):(\\d+):in [`'](.+?)': (.*) \\(([A-Z][\\w:]*)\\)$")
+	rubyFramePattern = regexp.MustCompile("^\\s+from (.+?):(\\d+):in [`'](.+?)'$")
+)
+
+// parseRubyBacktrace parses the last uncaught error:
+//
+//	/app/app.rb:3:in `divide': divided by 0 (ZeroDivisionError)
+//		from /app/app.rb:7:in `<main>'
+func parseRubyBacktrace(lines []string) (*models.CrashSignature, int) {
+	for start := len(lines) - 1; start >= 0; start-- {
+		match := rubyErrorPattern.FindStringSubmatch(strings.TrimSpace(lines[start]))
+		if match == nil {
+			continue
+		}
+
+		signature := &models.CrashSignature{
+			Language: "ruby",
+			Type:     match[5],
+			Message:  match[4],
+			Frames:   []string{fmt.Sprintf("%s (%s:%s)", match[3], match[1], match[2])},
+		}
+
+		for _, line := range lines[start+1:] {
+			frame := rubyFramePattern.FindStringSubmatch(line)
+			if frame == nil {
+				break
+			}
+
+			signature.Frames = appendCrashFrame(signature.Frames, fmt.Sprintf("%s (%s:%s)", frame[3], frame[1], frame[2]))
+		}
+
+		return signature, start
+	}
+
+	return nil, -1
+}
@@ -0,0 +1,302 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/porter-dev/porter-agent/pkg/models"
+)
+
+func TestExtractCrashSignature(t *testing.T) {
+	tests := []struct {
+		name     string
+		logs     string
+		expected *models.CrashSignature
+	}{
+		{
+			name: "go panic",
+			logs: `2023/01/10 12:00:01 listening on :8080
+2023/01/10 12:00:02 GET /users/3
+panic: runtime error: index out of range [3] with length 3
+
+goroutine 18 [running]:
+main.getUser(...)
+	/app/users.go:12
+main.handler({0x7f8e10, 0xc0001a0000}, 0xc000192000)
+	/app/main.go:27 +0x1d
+net/http.HandlerFunc.ServeHTTP(0xc000010000?, {0x7f8e10?, 0xc0001a0000?}, 0x0?)
+	/usr/local/go/src/net/http/server.go:2109 +0x2f
+created by net/http.(*Server).Serve
+	/usr/local/go/src/net/http/server.go:3102 +0x4db
+exit status 2`,
+			expected: &models.CrashSignature{
+				Language: "go",
+				Type:     "runtime error",
+				Message:  "index out of range [3] with length 3",
+				Frames: []string{
+					"main.getUser (/app/users.go:12)",
+					"main.handler (/app/main.go:27)",
+					"net/http.HandlerFunc.ServeHTTP (/usr/local/go/src/net/http/server.go:2109)",
+				},
+			},
+		},
+		{
+			name: "go panic through runtime frames",
+			logs: `panic: assignment to entry in nil map [recovered]
+
+goroutine 1 [running]:
+panic({0x4a2f40, 0x4e1b58})
+	/usr/local/go/src/runtime/panic.go:884 +0x213
+runtime.mapassign_faststr(0x0?, 0x0?, {0x4c1f2a, 0x3})
+	/usr/local/go/src/runtime/map_faststr.go:203 +0x3ed
+main.main()
+	/app/main.go:6 +0x2e
+`,
+			expected: &models.CrashSignature{
+				Language: "go",
+				Type:     "panic",
+				Message:  "assignment to entry in nil map",
+				Frames:   []string{"main.main (/app/main.go:6)"},
+			},
+		},
+		{
+			name: "python traceback",
+			logs: `INFO:root:starting worker
+Traceback (most recent call last):
+  File "/app/worker.py", line 14, in <module>
+    main()
+  File "/app/worker.py", line 10, in main
+    process(job)
+  File "/app/jobs.py", line 3, in process
+    return 1 / job.count
+           ~~^~~~~~~~~~~
+ZeroDivisionError: division by zero
+INFO:root:worker stopped`,
+			expected: &models.CrashSignature{
+				Language: "python",
+				Type:     "ZeroDivisionError",
+				Message:  "division by zero",
+				Frames: []string{
+					"process (/app/jobs.py:3)",
+					"main (/app/worker.py:10)",
+					"<module> (/app/worker.py:14)",
+				},
+			},
+		},
+		{
+			name: "chained python tracebacks",
+			logs: `Traceback (most recent call last):
+  File "/app/db.py", line 8, in connect
+    sock.connect(addr)
+ConnectionRefusedError: [Errno 111] Connection refused
+
+During handling of the above exception, another exception occurred:
+
+Traceback (most recent call last):
+  File "/app/app.py", line 20, in <module>
+    db.connect()
+  File "/app/db.py", line 10, in connect
+    raise DatabaseError("database is unreachable")
+db.DatabaseError: database is unreachable`,
+			expected: &models.CrashSignature{
+				Language: "python",
+				Type:     "db.DatabaseError",
+				Message:  "database is unreachable",
+				Frames: []string{
+					"connect (/app/db.py:10)",
+					"<module> (/app/app.py:20)",
+				},
+			},
+		},
+		{
+			name: "java exception caused by",
+			logs: `12:00:01.123 [main] INFO  com.example.App - Starting App
+Exception in thread "main" java.lang.IllegalStateException: failed to start
+	at com.example.App.start(App.java:31)
+	at com.example.App.main(App.java:12)
+Caused by: java.sql.SQLException: connection refused
+	at java.base/java.net.PlainSocketImpl.connect(PlainSocketImpl.java:101)
+	at com.example.db.Pool.connect(Pool.java:54)
+	at com.example.App.start(App.java:29)
+	... 1 more
+12:00:02.456 [main] INFO  com.example.App - Shutting down`,
+			expected: &models.CrashSignature{
+				Language: "java",
+				Type:     "java.sql.SQLException",
+				Message:  "connection refused",
+				Frames: []string{
+					"com.example.db.Pool.connect (Pool.java:54)",
+					"com.example.App.start (App.java:29)",
+				},
+			},
+		},
+		{
+			name: "kotlin exception",
+			logs: `Exception in thread "main" kotlin.KotlinNullPointerException
+	at kotlin.jvm.internal.Intrinsics.throwNpe(Intrinsics.java:19)
+	at com.example.ServerKt.main(Server.kt:8)`,
+			expected: &models.CrashSignature{
+				Language: "kotlin",
+				Type:     "kotlin.KotlinNullPointerException",
+				Frames:   []string{"com.example.ServerKt.main (Server.kt:8)"},
+			},
+		},
+		{
+			name: "node uncaught error",
+			logs: `> api@1.0.0 start
+> node index.js
+
+/app/index.js:10
+  return user.id;
+              ^
+
+TypeError: Cannot read properties of undefined (reading 'id')
+    at getUser (/app/index.js:10:15)
+    at /app/index.js:20:3
+    at Module._compile (node:internal/modules/cjs/loader:1105:14)
+    at node:internal/main/run_main_module:17:47
+
+Node.js v18.12.1`,
+			expected: &models.CrashSignature{
+				Language: "node",
+				Type:     "TypeError",
+				Message:  "Cannot read properties of undefined (reading 'id')",
+				Frames: []string{
+					"getUser (/app/index.js:10:15)",
+					"/app/index.js:20:3",
+				},
+			},
+		},
+		{
+			name: "ruby backtrace",
+			logs: `Puma starting in single mode...
+/app/lib/calculator.rb:3:in ` + "`" + `divide': divided by 0 (ZeroDivisionError)
+	from /app/lib/calculator.rb:7:in ` + "`" + `run'
+	from /app/app.rb:5:in ` + "`" + `<main>'`,
+			expected: &models.CrashSignature{
+				Language: "ruby",
+				Type:     "ZeroDivisionError",
+				Message:  "divided by 0",
+				Frames: []string{
+					"divide (/app/lib/calculator.rb:3)",
+					"run (/app/lib/calculator.rb:7)",
+					"<main> (/app/app.rb:5)",
+				},
+			},
+		},
+		{
+			name: "latest crash wins",
+			logs: `Traceback (most recent call last):
+  File "/app/app.py", line 2, in <module>
+    import missing
+ModuleNotFoundError: No module named 'missing'
+panic: boom
+
+goroutine 1 [running]:
+main.main()
+	/app/main.go:4 +0x27`,
+			expected: &models.CrashSignature{
+				Language: "go",
+				Type:     "panic",
+				Message:  "boom",
+				Frames:   []string{"main.main (/app/main.go:4)"},
+			},
+		},
+		{
+			name: "no crash",
+			logs: `2023/01/10 12:00:01 listening on :8080
+2023/01/10 12:00:02 error: connection reset by peer
+Error: something went wrong`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signature := ExtractCrashSignature(test.logs)
+
+			if !reflect.DeepEqual(signature, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, signature)
+			}
+
+			// logs may use Windows line endings
+			signature = ExtractCrashSignature(strings.ReplaceAll(test.logs, "\n", "\r\n"))
+
+			if !reflect.DeepEqual(signature, test.expected) {
+				t.Errorf("expected %+v with CRLF line endings, got %+v", test.expected, signature)
+			}
+		})
+	}
+}
+
+func TestExtractCrashSignatureKeepsTopFrames(t *testing.T) {
+	var logs strings.Builder
+
+	logs.WriteString("Exception in thread \"main\" java.lang.StackOverflowError\n")
+
+	for i := 0; i < 20; i++ {
+		logs.WriteString("\tat com.example.Tree.walk(Tree.java:42)\n")
+	}
+
+	signature := ExtractCrashSignature(logs.String())
+	if signature == nil {
+		t.Fatal("expected a crash signature")
+	}
+
+	if len(signature.Frames) != maxCrashFrames {
+		t.Errorf("expected %d frames, got %d", maxCrashFrames, len(signature.Frames))
+	}
+}
+
+func TestDescribeCrashSignature(t *testing.T) {
+	signature := &models.CrashSignature{
+		Language: "python",
+		Type:     "KeyError",
+		Message:  "'user_id'",
+		Frames:   []string{"handle (/app/app.py:12)", "main (/app/app.py:30)"},
+	}
+
+	expected := "The application crashed with KeyError (Python): 'user_id', at handle (/app/app.py:12)."
+
+	if description := DescribeCrashSignature(signature); description != expected {
+		t.Errorf("expected %q, got %q", expected, description)
+	}
+
+	signature.Message = strings.Repeat("x", maxCrashMessageLength+10)
+	signature.Frames = nil
+
+	expected = "The application crashed with KeyError (Python): " + strings.Repeat("x", maxCrashMessageLength) + "...."
+
+	if description := DescribeCrashSignature(signature); description != expected {
+		t.Errorf("expected %q, got %q", expected, description)
+	}
+}
+
+func TestGetEventSummary(t *testing.T) {
+	event := &models.PodEvent{
+		Message: "The application exited with a general error (exit code 1).",
+		ContainerEvents: map[string]*models.ContainerEvent{
+			"sidecar": {Name: "sidecar"},
+			"web": {
+				Name: "web",
+				CrashSignature: &models.CrashSignature{
+					Language: "go",
+					Type:     "panic",
+					Message:  "boom",
+				},
+			},
+		},
+	}
+
+	expected := "The application exited with a general error (exit code 1). The application crashed with panic (Go): boom."
+
+	if summary := GetEventSummary(event); summary != expected {
+		t.Errorf("expected %q, got %q", expected, summary)
+	}
+
+	event.ContainerEvents["web"].CrashSignature = nil
+
+	if summary := GetEventSummary(event); summary != event.Message {
+		t.Errorf("expected %q without a crash, got %q", event.Message, summary)
+	}
+}
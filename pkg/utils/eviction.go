@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	evictionThreshold int
+	evictionWindow    time.Duration
+
+	evictionResourceRegex = regexp.MustCompile(`low on resource: ([a-zA-Z0-9\-\./]+)`)
+)
+
+func init() {
+	viper.SetDefault("EVICTION_THRESHOLD", 2)
+	viper.SetDefault("EVICTION_WINDOW", "1h")
+	viper.AutomaticEnv()
+
+	evictionThreshold = viper.GetInt("EVICTION_THRESHOLD")
+	evictionWindow = viper.GetDuration("EVICTION_WINDOW")
+}
+
+// IsEvicted returns true if the pod was evicted from its node
+func IsEvicted(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodFailed && pod.Status.Reason == "Evicted"
+}
+
+// EvictionRequeueAfter returns the time after which an evicted pod no longer counts towards
+// the evictions of its release
+func EvictionRequeueAfter(pod *corev1.Pod) time.Duration {
+	if !IsEvicted(pod) {
+		return 0
+	}
+
+	if remaining := time.Until(getEvictedAt(pod).Add(evictionWindow)); remaining > 0 {
+		return remaining
+	}
+
+	return 0
+}
+
+// getEvictionResult returns a result for the evicted pod only if the release of the pod
+// has been evicted repeatedly within the eviction window, since a single eviction is
+// usually recovered from by rescheduling the pod.
+func (f *AgentPodFilter) getEvictionResult(pod *corev1.Pod) *FilteredMessageResult {
+	evictedAt := getEvictedAt(pod)
+
+	if time.Since(evictedAt) > evictionWindow {
+		return nil
+	}
+
+	count := 1
+
+	if releaseName := f.releaseResolver.GetReleaseName(context.Background(), pod); releaseName != "" {
+		pods, err := f.releaseResolver.ListReleasePods(context.Background(), pod.Namespace, releaseName)
+
+		if err == nil {
+			for i := range pods {
+				if pods[i].Name != pod.Name && IsEvicted(&pods[i]) &&
+					time.Since(getEvictedAt(&pods[i])) <= evictionWindow {
+					count++
+				}
+			}
+		}
+	}
+
+	if count < evictionThreshold {
+		return nil
+	}
+
+	resource := getEvictionResource(pod.Status.Message)
+
+	res := &FilteredMessageResult{
+		Eviction: &models.EvictionEvent{
+			Message:  pod.Status.Message,
+			NodeName: pod.Spec.NodeName,
+			Resource: resource,
+			Count:    count,
+		},
+		Severity: models.SeverityMedium,
+	}
+
+	if resource != "" {
+		res.PodSummary = fmt.Sprintf("The application was evicted because its node was low on %s", resource)
+	} else {
+		res.PodSummary = "The application was evicted from its node"
+	}
+
+	res.PodDetails = fmt.Sprintf("The pod %s was evicted from node %s: %s The application has been evicted %d times "+
+		"in the last %s. ", pod.Name, pod.Spec.NodeName, strings.TrimSpace(pod.Status.Message), count, evictionWindow)
+
+	switch resource {
+	case "memory":
+		res.PodDetails += "Set the memory request of the application closer to its actual usage, so that it is " +
+			"scheduled on a node with enough memory available."
+	case "ephemeral-storage":
+		res.PodDetails += "Reduce the disk space used by the application, or set ephemeral storage requests and " +
+			"limits for the application so that it is scheduled on a node with enough disk space available."
+	default:
+		res.PodDetails += "Make sure that the resource requests of the application match its actual usage."
+	}
+
+	return res
+}
+
+func getEvictionResource(message string) string {
+	if matches := evictionResourceRegex.FindStringSubmatch(message); len(matches) > 1 {
+		return strings.TrimSuffix(matches[1], ".")
+	}
+
+	// the kubelet reports pods exceeding their own ephemeral storage limits differently
+	if strings.Contains(message, "ephemeral local storage") {
+		return "ephemeral-storage"
+	}
+
+	return ""
+}
+
+// the kubelet does not record the time of the eviction itself, so we use the latest
+// transition of the pod's conditions, which are updated when the pod is evicted
+func getEvictedAt(pod *corev1.Pod) time.Time {
+	var evictedAt time.Time
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.LastTransitionTime.After(evictedAt) {
+			evictedAt = condition.LastTransitionTime.Time
+		}
+	}
+
+	if evictedAt.IsZero() {
+		evictedAt = pod.CreationTimestamp.Time
+	}
+
+	return evictedAt
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// processes killed by a signal exit with 128 plus the number of the signal
+const signalExitCodeOffset = 128
+
+const exitCodeDocs = "We recommend looking into https://docs.porter.run/managing-applications/alerting/pod-exit-codes " +
+	"to further debug the reason for the crash."
+
+// refer: https://www.man7.org/linux/man-pages/man7/signal.7.html
+var containerSignals = map[int32]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	5:  "SIGTRAP",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	11: "SIGSEGV",
+	13: "SIGPIPE",
+	15: "SIGTERM",
+}
+
+type exitCodeDescription struct {
+	// summary is formatted with the kind of the container, see getContainerKind
+	summary string
+	details string
+}
+
+// well-known exit codes of applications, which are explained instead of only being reported
+var knownExitCodes = map[int32]exitCodeDescription{
+	1: {
+		summary: "The %s exited with a general error (exit code 1)",
+		details: "Exit code 1 is used by most applications for unhandled errors, such as an uncaught exception. " +
+			"Check the logs of the application for the error it reported.",
+	},
+	2: {
+		summary: "The %s exited because it was invoked incorrectly (exit code 2)",
+		details: "Exit code 2 usually means that the command of the application was given invalid arguments, " +
+			"or that a shell script run by the application has a syntax error. Check the command and the " +
+			"arguments of the container.",
+	},
+	126: {
+		summary: "The command of the %s could not be executed (exit code 126)",
+		details: "Exit code 126 means that the command of the container was found but could not be run, " +
+			"usually because the file is not executable or its permissions do not allow the user of the " +
+			"container to run it.",
+	},
+	127: {
+		summary: "The command of the %s was not found (exit code 127)",
+		details: "Exit code 127 means that the command of the container, or a command run by its script, " +
+			"could not be found. Check that the command exists in the image and is on the PATH of the container.",
+	},
+	139: {
+		summary: "The %s crashed with a segmentation fault (exit code 139, SIGSEGV)",
+		details: "Exit code 139 means that the application was killed by SIGSEGV because it accessed memory " +
+			"which it is not allowed to, which usually points to a bug in native code used by the application.",
+	},
+	143: {
+		summary: "The %s was stopped by SIGTERM (exit code 143)",
+		details: "Exit code 143 means that the application was asked to stop with SIGTERM and exited because " +
+			"of it, which happens when the pod is shut down or when its liveness probe fails. If this is " +
+			"unexpected, check the events of the pod.",
+	},
+}
+
+// GetExitCodeSignal returns the name of the signal which killed a process that exited with the
+// exit code, or an empty string if the process was not killed by a known signal
+func GetExitCodeSignal(exitCode int32) string {
+	if exitCode <= signalExitCodeOffset {
+		return ""
+	}
+
+	return containerSignals[exitCode-signalExitCodeOffset]
+}
+
+// getContainerKind returns how the summaries of a container refer to it
+func getContainerKind(isInit bool) string {
+	if isInit {
+		return "init container"
+	}
+
+	return "application"
+}
+
+// DescribeExitCode returns the summary and details of a container which exited with the exit code.
+// The summary refers to init containers as such.
+func DescribeExitCode(exitCode int32, isInit bool) (string, string) {
+	kind := getContainerKind(isInit)
+
+	if description, ok := knownExitCodes[exitCode]; ok {
+		return fmt.Sprintf(description.summary, kind), fmt.Sprintf("%s %s", description.details, exitCodeDocs)
+	}
+
+	if signal := GetExitCodeSignal(exitCode); signal != "" {
+		return fmt.Sprintf("The %s was killed by %s (exit code %d)", kind, signal, exitCode),
+			fmt.Sprintf("Exit code %d means that the application was killed by signal %s. %s",
+				exitCode, signal, exitCodeDocs)
+	}
+
+	return fmt.Sprintf("The %s exited with exit code %d", kind, exitCode),
+		fmt.Sprintf("The application exited with exit code %d. %s", exitCode, exitCodeDocs)
+}
+
+// getContainerTermination returns the termination of the container, which is its current state
+// or its last state while it is waiting to be restarted after crashing
+func getContainerTermination(status corev1.ContainerStatus) *corev1.ContainerStateTerminated {
+	if status.State.Waiting != nil && status.State.Waiting.Reason != "" {
+		if status.State.Waiting.Reason == "CrashLoopBackOff" {
+			return status.LastTerminationState.Terminated
+		}
+
+		return nil
+	}
+
+	return status.State.Terminated
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetExitCodeSignal(t *testing.T) {
+	tests := map[int32]string{
+		0:   "",
+		1:   "",
+		128: "",
+		129: "SIGHUP",
+		130: "SIGINT",
+		134: "SIGABRT",
+		137: "SIGKILL",
+		139: "SIGSEGV",
+		143: "SIGTERM",
+		// signal 10 is not one of the signals we name
+		138: "",
+		255: "",
+	}
+
+	for exitCode, expected := range tests {
+		if signal := GetExitCodeSignal(exitCode); signal != expected {
+			t.Errorf("expected signal %q for exit code %d, got %q", expected, exitCode, signal)
+		}
+	}
+}
+
+func TestDescribeExitCode(t *testing.T) {
+	tests := []struct {
+		exitCode        int32
+		isInit          bool
+		expectedSummary string
+		expectedDetails string
+	}{
+		{
+			exitCode:        1,
+			expectedSummary: "The application exited with a general error (exit code 1)",
+			expectedDetails: "Exit code 1 is used by most applications",
+		},
+		{
+			exitCode:        1,
+			isInit:          true,
+			expectedSummary: "The init container exited with a general error (exit code 1)",
+			expectedDetails: "Exit code 1 is used by most applications",
+		},
+		{
+			exitCode:        2,
+			expectedSummary: "The application exited because it was invoked incorrectly (exit code 2)",
+			expectedDetails: "Exit code 2 usually means",
+		},
+		{
+			exitCode:        126,
+			isInit:          true,
+			expectedSummary: "The command of the init container could not be executed (exit code 126)",
+			expectedDetails: "Exit code 126 means",
+		},
+		{
+			exitCode:        127,
+			expectedSummary: "The command of the application was not found (exit code 127)",
+			expectedDetails: "Exit code 127 means",
+		},
+		{
+			exitCode:        137,
+			expectedSummary: "The application was killed by SIGKILL (exit code 137)",
+			expectedDetails: "Exit code 137 means that the application was killed by signal SIGKILL.",
+		},
+		{
+			exitCode:        137,
+			isInit:          true,
+			expectedSummary: "The init container was killed by SIGKILL (exit code 137)",
+			expectedDetails: "Exit code 137 means that the application was killed by signal SIGKILL.",
+		},
+		{
+			exitCode:        134,
+			expectedSummary: "The application was killed by SIGABRT (exit code 134)",
+			expectedDetails: "Exit code 134 means that the application was killed by signal SIGABRT.",
+		},
+		{
+			exitCode:        139,
+			expectedSummary: "The application crashed with a segmentation fault (exit code 139, SIGSEGV)",
+			expectedDetails: "Exit code 139 means",
+		},
+		{
+			exitCode:        143,
+			isInit:          true,
+			expectedSummary: "The init container was stopped by SIGTERM (exit code 143)",
+			expectedDetails: "Exit code 143 means",
+		},
+		{
+			exitCode:        3,
+			expectedSummary: "The application exited with exit code 3",
+			expectedDetails: "The application exited with exit code 3.",
+		},
+		{
+			exitCode:        255,
+			isInit:          true,
+			expectedSummary: "The init container exited with exit code 255",
+			expectedDetails: "The application exited with exit code 255.",
+		},
+	}
+
+	for _, test := range tests {
+		summary, details := DescribeExitCode(test.exitCode, test.isInit)
+
+		if summary != test.expectedSummary {
+			t.Errorf("expected summary %q for exit code %d, got %q", test.expectedSummary, test.exitCode, summary)
+		}
+
+		if !strings.HasPrefix(details, test.expectedDetails) || !strings.HasSuffix(details, exitCodeDocs) {
+			t.Errorf("expected details for exit code %d to start with %q and link the docs, got %q",
+				test.exitCode, test.expectedDetails, details)
+		}
+	}
+}
+
+func TestFilterInitContainer(t *testing.T) {
+	pod := newTestPod(corev1.PodPending)
+	pod.Status.InitContainerStatuses = []corev1.ContainerStatus{
+		newCrashLoopStatus(corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}),
+	}
+	pod.Status.InitContainerStatuses[0].Name = "migrate"
+
+	res := newTestPodFilter().Filter(pod, false)
+	if res == nil {
+		t.Fatal("expected failed init container to be reported")
+	}
+
+	if expected := "The init container exited with a general error (exit code 1) before the application started"; res.PodSummary != expected {
+		t.Errorf("expected summary %q, got %q", expected, res.PodSummary)
+	}
+
+	if expected := "The init container migrate failed, so the application was never started. "; !strings.HasPrefix(res.PodDetails, expected) {
+		t.Errorf("expected details to start with %q, got %q", expected, res.PodDetails)
+	}
+}
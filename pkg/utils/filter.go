@@ -6,25 +6,36 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/porter-dev/porter-agent/pkg/models"
 	"github.com/spf13/viper"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
-var porterHost string
+var (
+	porterHost               string
+	unschedulableGracePeriod time.Duration
+)
 
 type FilteredMessageResult struct {
 	PodSummary        string
 	PodDetails        string
 	ContainerStatuses []*FilteredMessageContainerResult
+	Eviction          *models.EvictionEvent
+	Severity          models.Severity
 }
 
 type FilteredMessageContainerResult struct {
 	ContainerName string
+	IsInit        bool
 	Summary       string
 	Details       string
+	Severity      models.Severity
+	ExitCode      int32
+	Signal        string
 }
 
 type PodFilter interface {
@@ -32,168 +43,64 @@ type PodFilter interface {
 }
 
 type AgentPodFilter struct {
-	kubeClient *kubernetes.Clientset
+	kubeClient      kubernetes.Interface
+	releaseResolver *ReleaseResolver
 }
 
 func init() {
+	viper.SetDefault("UNSCHEDULABLE_GRACE_PERIOD", "5m")
+	viper.AutomaticEnv()
+
 	porterHost = viper.GetString("PORTER_HOST")
+	unschedulableGracePeriod = viper.GetDuration("UNSCHEDULABLE_GRACE_PERIOD")
 }
 
-func NewAgentPodFilter(kubeClient *kubernetes.Clientset) PodFilter {
+func NewAgentPodFilter(kubeClient *kubernetes.Clientset, releaseResolver *ReleaseResolver) PodFilter {
 	return &AgentPodFilter{
-		kubeClient: kubeClient,
+		kubeClient:      kubeClient,
+		releaseResolver: releaseResolver,
 	}
 }
 
 func (f *AgentPodFilter) Filter(pod *corev1.Pod, isJob bool) *FilteredMessageResult {
 	res := &FilteredMessageResult{}
 
-	for i := len(pod.Status.ContainerStatuses) - 1; i >= 0; i-- {
-		status := pod.Status.ContainerStatuses[i]
-
-		if isJob && (status.Name == "sidecar" || status.Name == "cloud-sql-proxy") {
-			continue
+	// a pod which never gets scheduled has no container statuses to look at
+	if condition := getUnschedulableCondition(pod); condition != nil {
+		if time.Since(condition.LastTransitionTime.Time) < unschedulableGracePeriod {
+			return nil
 		}
 
-		scaleDownEvent := f.getContainerEventForReasons(pod.Name, pod.Namespace, status.Name, "ScaleDown")
-		if scaleDownEvent != nil && strings.Contains(scaleDownEvent.Message, "deleting pod for node scale down") {
-			continue
-		}
-
-		// if the exit code is 255, we check that the job doesn't have a different associated pod.
-		// exit code 255 can mean this pod was moved to a different node due to node eviction, scaledown,
-		// unhealthy node, etc
-		if (status.State.Terminated != nil && status.State.Terminated.ExitCode == 255) ||
-			(status.LastTerminationState.Terminated != nil && status.LastTerminationState.Terminated.ExitCode == 255) {
-			pods, err := f.kubeClient.CoreV1().Pods(pod.Namespace).List(
-				context.Background(), v1.ListOptions{
-					LabelSelector: fmt.Sprintf("app.kubernetes.io/instance=%s", pod.Labels["app.kubernetes.io/instance"]),
-				},
-			)
-
-			if err == nil && len(pods.Items) > 0 {
-				shouldContinue := false
-
-				for _, ownerPod := range pods.Items {
-					if ownerPod.ObjectMeta.Name != pod.Name {
-						shouldContinue = true
-						break
-					}
-				}
-
-				if shouldContinue {
-					continue
-				}
-			}
-		}
-
-		containerResult := &FilteredMessageContainerResult{
-			ContainerName: status.Name,
-		}
+		return f.getUnschedulableResult(pod, condition)
+	}
 
-		if status.State.Waiting != nil && status.State.Waiting.Reason != "" {
-			if status.State.Waiting.Reason == "CrashLoopBackOff" {
-				if status.LastTerminationState.Terminated != nil {
-					if status.LastTerminationState.Terminated.Reason == "Error" {
-						containerResult.Summary = fmt.Sprintf("The application exited with exit code %d",
-							status.LastTerminationState.Terminated.ExitCode)
-
-						if status.LastTerminationState.Terminated.ExitCode == 137 {
-							// check for possible Killing or Unhealthy events for this container
-							event := f.getContainerEventForReasons(
-								pod.Name, pod.Namespace, status.Name, "Killing", "Unhealthy",
-							)
-
-							if event != nil {
-								containerResult.Details = event.Message
-							}
-						}
-
-						if containerResult.Details == "" {
-							containerResult.Details = fmt.Sprintf("The application exited with exit code %d. "+
-								"We recommend looking into https://docs.porter.run/managing-applications/alerting/pod-exit-codes "+
-								"to further debug the reason for the crash.",
-								status.LastTerminationState.Terminated.ExitCode)
-						}
-					} else if status.LastTerminationState.Terminated.Reason == "OOMKilled" {
-						containerResult.Summary = "The application was killed because it used too much memory"
-						containerResult.Details = fmt.Sprintf("The application exceeded its memory limit of %s. ",
-							pod.Spec.Containers[0].Resources.Limits.Memory().String())
-
-						containerResult.Details += "Reduce the amount of memory your application is using or increase the memory limit - " +
-							"see the docs here for more information: https://docs.porter.run/managing-applications/" +
-							"application-troubleshooting#memory-usage"
-					} else if status.LastTerminationState.Terminated.Reason == "ContainerCannotRun" ||
-						status.LastTerminationState.Terminated.Reason == "StartError" {
-						containerResult.Summary = "The application could not start running"
-						containerResult.Details = getFilteredMessage(status.LastTerminationState.Terminated.Message)
-					}
-				}
-			} else if status.State.Waiting.Reason == "ErrImagePull" ||
-				status.State.Waiting.Reason == "ImagePullBackOff" {
-				containerResult.Summary = "The image could not be pulled from the registry"
-				containerResult.Details = fmt.Sprintf("The application was unable to pull image %s. "+
-					"Please make sure you have linked this image registry to Porter by navigating to %s/"+
-					"integrations/registry. See documentation for linking your registry here: "+
-					"https://docs.porter.run/deploying-applications/deploying-from-docker-registry/linking-existing-registry",
-					status.Image, porterHost)
-			} else if status.State.Waiting.Reason == "InvalidImageName" {
-				containerResult.Summary = "The image could not be pulled from the registry because the image URI is invalid"
-				containerResult.Details = fmt.Sprintf("The specified image %s is not a valid image URI.", status.Image)
-			}
-			// FIXME: check for RunContainerError
-		} else if status.State.Terminated != nil && status.State.Terminated.Reason != "" {
-			if status.State.Terminated.Reason == "Error" {
-				containerResult.Summary = fmt.Sprintf("The application exited with exit code %d",
-					status.State.Terminated.ExitCode)
-
-				if status.State.Terminated.ExitCode == 137 {
-					// check for possible Killing or Unhealthy events for this container
-					event := f.getContainerEventForReasons(
-						pod.Name, pod.Namespace, status.Name, "Killing", "Unhealthy",
-					)
-
-					if event != nil {
-						containerResult.Details = event.Message
-					}
-				}
+	// the container statuses of an evicted pod rarely say why it was stopped
+	if IsEvicted(pod) {
+		return f.getEvictionResult(pod)
+	}
 
-				if containerResult.Details == "" {
-					containerResult.Details = fmt.Sprintf("The application exited with exit code %d. "+
-						"We recommend looking into https://docs.porter.run/managing-applications/alerting/pod-exit-codes "+
-						"to further debug the reason for the crash.",
-						status.State.Terminated.ExitCode)
-				}
-			} else if status.State.Terminated.Reason == "OOMKilled" {
-				containerResult.Summary = "The application was killed because it used too much memory"
-				containerResult.Details = fmt.Sprintf("The application exceeded its memory limit of %s. ",
-					pod.Spec.Containers[0].Resources.Limits.Memory().String())
-
-				containerResult.Details += "Reduce the amount of memory your application is using or increase the memory limit - " +
-					"see the docs here for more information: https://docs.porter.run/managing-applications/" +
-					"application-troubleshooting#memory-usage"
-			} else if status.State.Terminated.Reason == "ContainerCannotRun" ||
-				status.State.Terminated.Reason == "StartError" {
-				containerResult.Summary = "The application could not start running"
-				containerResult.Details = getFilteredMessage(status.State.Terminated.Message)
-			}
-		} else if status.State.Terminated != nil && status.State.Terminated.Reason == "" {
-			containerResult.Summary = fmt.Sprintf("The application exited with exit code %d",
-				status.State.Terminated.ExitCode)
-			containerResult.Details = fmt.Sprintf("The application exited with exit code %d. "+
-				"We recommend looking into https://docs.porter.run/managing-applications/alerting/pod-exit-codes "+
-				"to further debug the reason for the crash.",
-				status.State.Terminated.ExitCode)
+	// init containers run before the application containers, so their failures are listed first
+	for i := len(pod.Status.InitContainerStatuses) - 1; i >= 0; i-- {
+		if containerResult := f.filterContainerStatus(pod, pod.Status.InitContainerStatuses[i], isJob, true); containerResult != nil {
+			res.ContainerStatuses = append(res.ContainerStatuses, containerResult)
 		}
+	}
 
-		if containerResult.Details != "" && containerResult.Summary != "" {
+	for i := len(pod.Status.ContainerStatuses) - 1; i >= 0; i-- {
+		if containerResult := f.filterContainerStatus(pod, pod.Status.ContainerStatuses[i], isJob, false); containerResult != nil {
 			res.ContainerStatuses = append(res.ContainerStatuses, containerResult)
 		}
 	}
 
 	if len(res.ContainerStatuses) == 0 {
 		return nil
-	} else if len(res.ContainerStatuses) == 1 {
+	}
+
+	for _, containerResult := range res.ContainerStatuses {
+		res.Severity = models.MaxSeverity(res.Severity, containerResult.Severity)
+	}
+
+	if len(res.ContainerStatuses) == 1 {
 		res.PodSummary = res.ContainerStatuses[0].Summary
 		res.PodDetails = res.ContainerStatuses[0].Details
 	} else { // more than one container
@@ -212,15 +119,164 @@ func (f *AgentPodFilter) Filter(pod *corev1.Pod, isJob bool) *FilteredMessageRes
 	return res
 }
 
+func (f *AgentPodFilter) filterContainerStatus(
+	pod *corev1.Pod, status corev1.ContainerStatus, isJob, isInit bool,
+) *FilteredMessageContainerResult {
+	if !isInit && isJob && (status.Name == "sidecar" || status.Name == "cloud-sql-proxy") {
+		return nil
+	}
+
+	fieldPath := getContainerFieldPath(status.Name, isInit)
+
+	scaleDownEvent := f.getContainerEventForReasons(pod.Name, pod.Namespace, fieldPath, "ScaleDown")
+	if scaleDownEvent != nil && strings.Contains(scaleDownEvent.Message, "deleting pod for node scale down") {
+		return nil
+	}
+
+	// if the exit code is 255, we check that the job doesn't have a different associated pod.
+	// exit code 255 can mean this pod was moved to a different node due to node eviction, scaledown,
+	// unhealthy node, etc
+	if (status.State.Terminated != nil && status.State.Terminated.ExitCode == 255) ||
+		(status.LastTerminationState.Terminated != nil && status.LastTerminationState.Terminated.ExitCode == 255) {
+		pods, err := f.releaseResolver.ListReleasePods(
+			context.Background(), pod.Namespace, f.releaseResolver.GetReleaseName(context.Background(), pod),
+		)
+
+		if err == nil && len(pods) > 0 {
+			shouldContinue := false
+
+			for _, ownerPod := range pods {
+				if ownerPod.ObjectMeta.Name != pod.Name {
+					shouldContinue = true
+					break
+				}
+			}
+
+			if shouldContinue {
+				return nil
+			}
+		}
+	}
+
+	containerResult := &FilteredMessageContainerResult{
+		ContainerName: status.Name,
+		IsInit:        isInit,
+	}
+
+	containerResult.Summary, containerResult.Details, containerResult.Severity = f.applyFilterRules(pod, status, fieldPath, isInit)
+
+	if containerResult.Details == "" || containerResult.Summary == "" {
+		return nil
+	}
+
+	if terminated := getContainerTermination(status); terminated != nil {
+		containerResult.ExitCode = terminated.ExitCode
+		containerResult.Signal = GetExitCodeSignal(terminated.ExitCode)
+	}
+
+	if isInit {
+		containerResult.Summary += " before the application started"
+		containerResult.Details = fmt.Sprintf("The init container %s failed, so the application was never started. %s",
+			status.Name, containerResult.Details)
+	}
+
+	return containerResult
+}
+
+func (f *AgentPodFilter) getUnschedulableResult(pod *corev1.Pod, condition *corev1.PodCondition) *FilteredMessageResult {
+	message := condition.Message
+
+	if event := f.getPodEventForReasons(pod.Name, pod.Namespace, "FailedScheduling"); event != nil {
+		message = event.Message
+	}
+
+	// the scheduler appends the outcome of preemption, which is rarely useful to the user
+	if idx := strings.Index(message, " preemption:"); idx != -1 {
+		message = message[:idx]
+	}
+
+	message = strings.TrimSpace(message)
+
+	if !strings.HasSuffix(message, ".") {
+		message += "."
+	}
+
+	res := &FilteredMessageResult{
+		PodSummary: fmt.Sprintf("The application cannot be scheduled: %s", message),
+		PodDetails: fmt.Sprintf("The pod %s could not be scheduled on any node: %s ", pod.Name, message),
+		Severity:   models.SeverityHigh,
+	}
+
+	if event := f.getPodEventForReasons(pod.Name, pod.Namespace, "NotTriggerScaleUp"); event != nil {
+		res.PodDetails += fmt.Sprintf("The cluster autoscaler did not add a new node for this pod: %s. ", event.Message)
+	}
+
+	res.PodDetails += "Reduce the resources requested by the application, make sure that the pod tolerates the " +
+		"taints of your nodes, or add more nodes to the cluster - see the docs here for more information: " +
+		"https://docs.porter.run/managing-applications/application-troubleshooting"
+
+	return res
+}
+
+// UnschedulableRequeueAfter returns the time after which an unschedulable pod should be
+// checked again, once its grace period is over. It returns 0 for any other pod.
+func UnschedulableRequeueAfter(pod *corev1.Pod) time.Duration {
+	condition := getUnschedulableCondition(pod)
+	if condition == nil {
+		return 0
+	}
+
+	if remaining := time.Until(condition.LastTransitionTime.Add(unschedulableGracePeriod)); remaining > 0 {
+		return remaining
+	}
+
+	return 0
+}
+
+func getUnschedulableCondition(pod *corev1.Pod) *corev1.PodCondition {
+	if pod.Status.Phase != corev1.PodPending {
+		return nil
+	}
+
+	for i := range pod.Status.Conditions {
+		condition := pod.Status.Conditions[i]
+
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse &&
+			condition.Reason == corev1.PodReasonUnschedulable {
+			return &condition
+		}
+	}
+
+	return nil
+}
+
+func (f *AgentPodFilter) getPodEventForReasons(podName, namespace string, reasons ...string) *corev1.Event {
+	for _, reason := range reasons {
+		events, err := f.kubeClient.CoreV1().Events(namespace).List(
+			context.Background(), v1.ListOptions{
+				FieldSelector: fmt.Sprintf("involvedObject.kind=Pod,involvedObject.name=%s,reason=%s",
+					podName, reason),
+			},
+		)
+
+		if err == nil && len(events.Items) > 0 {
+			f.sortEventsByCreationTimestamp(events.Items)
+			return events.Items[0].DeepCopy()
+		}
+	}
+
+	return nil
+}
+
 func (f *AgentPodFilter) getContainerEventForReasons(
-	podName, namespace, containerName string, reasons ...string,
+	podName, namespace, fieldPath string, reasons ...string,
 ) *corev1.Event {
 	for _, reason := range reasons {
 		events, err := f.kubeClient.CoreV1().Events(namespace).List(
 			context.Background(), v1.ListOptions{
 				FieldSelector: fmt.Sprintf(
-					"involvedObject.name=%s,reason=%s,involvedObject.fieldPath=spec.containers{%s}",
-					podName, reason, containerName),
+					"involvedObject.name=%s,reason=%s,involvedObject.fieldPath=%s",
+					podName, reason, fieldPath),
 			},
 		)
 
@@ -239,6 +295,26 @@ func (f *AgentPodFilter) sortEventsByCreationTimestamp(events []corev1.Event) {
 	})
 }
 
+func getContainerFieldPath(containerName string, isInit bool) string {
+	if isInit {
+		return fmt.Sprintf("spec.initContainers{%s}", containerName)
+	}
+
+	return fmt.Sprintf("spec.containers{%s}", containerName)
+}
+
+func getMemoryLimit(pod *corev1.Pod, containerName string) string {
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			if container.Name == containerName {
+				return container.Resources.Limits.Memory().String()
+			}
+		}
+	}
+
+	return pod.Spec.Containers[0].Resources.Limits.Memory().String()
+}
+
 func getFilteredMessage(message string) string {
 	regex := regexp.MustCompile("starting container process caused:.*$")
 	matches := regex.FindStringSubmatch(message)
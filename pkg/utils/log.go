@@ -1,13 +1,67 @@
 package utils
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// logs are of the form "log:<incident_id>:<timestamp>"
+// logs are stored gzip-compressed under "log:sha256:<digest>", where the digest is the SHA-256
+// hash of the logs, so that the same logs captured from several pods or restarts are stored once
+const contentLogIDPrefix = "log:sha256:"
+
+// NewLogID returns the content-addressed ID of the logs
+func NewLogID(strLogs string) string {
+	digest := sha256.Sum256([]byte(strLogs))
+
+	return contentLogIDPrefix + hex.EncodeToString(digest[:])
+}
+
+// CompressLogs returns the logs compressed with gzip
+func CompressLogs(strLogs string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writer := gzip.NewWriter(&buf)
+
+	if _, err := writer.Write([]byte(strLogs)); err != nil {
+		return nil, fmt.Errorf("error compressing logs: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error compressing logs: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// DecompressLogs returns the logs stored by CompressLogs. Logs stored before they were compressed
+// are returned as they are.
+func DecompressLogs(data []byte) (string, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return string(data), nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("error decompressing logs: %w", err)
+	}
+	defer reader.Close()
+
+	logs, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("error decompressing logs: %w", err)
+	}
+
+	return string(logs), nil
+}
+
+// logs stored before they were content-addressed are of the form "log:<incident_id>:<timestamp>"
 type Log struct {
 	incident  *Incident
 	timestamp int64
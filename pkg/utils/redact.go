@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/spf13/viper"
+)
+
+// redactedSecret replaces the secrets found in logs and messages
+const redactedSecret = "[REDACTED]"
+
+var (
+	redactionEnabled bool
+	secretDetectors  []*secretDetector
+)
+
+func init() {
+	viper.SetDefault("REDACTION_ENABLED", true)
+	viper.SetDefault("REDACTION_PATTERNS", "")
+	viper.AutomaticEnv()
+
+	redactionEnabled = viper.GetBool("REDACTION_ENABLED")
+
+	detectors, err := loadSecretDetectors(viper.GetString("REDACTION_PATTERNS"))
+	if err != nil {
+		panic(fmt.Sprintf("cannot load redaction patterns: %v", err))
+	}
+
+	secretDetectors = detectors
+}
+
+// secretDetector finds secrets with a pattern, and replaces them with the expanded replacement so
+// that the parts around a secret, like the key of a password, are kept
+type secretDetector struct {
+	name        string
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// the built-in detectors, tried in order. Private keys go first since their blocks can hold
+// anything the other detectors would match.
+var builtinSecretDetectors = []*secretDetector{
+	{
+		name:        "private_key",
+		pattern:     regexp.MustCompile(`-----BEGIN ([A-Z0-9 ]*PRIVATE KEY)-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY-----`),
+		replacement: "-----BEGIN ${1}-----" + redactedSecret + "-----END ${1}-----",
+	},
+	{
+		name:        "url_credentials",
+		pattern:     regexp.MustCompile(`\b([a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]*):[^/\s@]+@`),
+		replacement: "${1}:" + redactedSecret + "@",
+	},
+	{
+		name:        "jwt",
+		pattern:     regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+		replacement: redactedSecret,
+	},
+	{
+		// tokens are at least 16 characters long, so that text like "the bearer of" is kept
+		name:        "bearer_token",
+		pattern:     regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9._~+/-]{16,}=*`),
+		replacement: "${1}" + redactedSecret,
+	},
+	{
+		name:        "aws_access_key_id",
+		pattern:     regexp.MustCompile(`\b(?:AKIA|ASIA|AROA|AIDA)[0-9A-Z]{16}\b`),
+		replacement: redactedSecret,
+	},
+	{
+		name:        "aws_secret_access_key",
+		pattern:     regexp.MustCompile(`(?i)(aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?)[A-Za-z0-9/+=]{40}`),
+		replacement: "${1}" + redactedSecret,
+	},
+	{
+		name:        "password",
+		pattern:     regexp.MustCompile(`(?i)(\b[a-z0-9_.-]*(?:password|passwd|pwd)["']?\s*[:=]\s*)(?:"[^"]*"|'[^']*'|\[REDACTED\]|[^\s&,;"'}]+)`),
+		replacement: "${1}" + redactedSecret,
+	},
+}
+
+// loadSecretDetectors returns the built-in detectors followed by a detector for each of the
+// regular expressions in the JSON array, which replace the whole match
+func loadSecretDetectors(patterns string) ([]*secretDetector, error) {
+	detectors := append([]*secretDetector{}, builtinSecretDetectors...)
+
+	if patterns == "" {
+		return detectors, nil
+	}
+
+	var exprs []string
+
+	if err := json.Unmarshal([]byte(patterns), &exprs); err != nil {
+		return nil, fmt.Errorf("redaction patterns must be a JSON array of regular expressions: %w", err)
+	}
+
+	for i, expr := range exprs {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", expr, err)
+		}
+
+		detectors = append(detectors, &secretDetector{
+			name:        fmt.Sprintf("custom_%d", i),
+			pattern:     pattern,
+			replacement: redactedSecret,
+		})
+	}
+
+	return detectors, nil
+}
+
+// redact returns the text with the secrets replaced, along with the number of secrets found.
+// Secrets which were redacted already are left as they are and are not counted, so that text can
+// be redacted more than once.
+func (d *secretDetector) redact(text string) (string, int) {
+	matches := d.pattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text, 0
+	}
+
+	var res []byte
+	last := 0
+	count := 0
+
+	for _, match := range matches {
+		res = append(res, text[last:match[0]]...)
+
+		start := len(res)
+		res = d.pattern.ExpandString(res, d.replacement, text, match)
+
+		if string(res[start:]) != text[match[0]:match[1]] {
+			count++
+		}
+
+		last = match[1]
+	}
+
+	res = append(res, text[last:]...)
+
+	return string(res), count
+}
+
+// RedactSecrets returns the text with the secrets found by the built-in and configured detectors
+// replaced, along with the number of secrets found by each detector
+func RedactSecrets(text string) (string, map[string]int) {
+	if !redactionEnabled || text == "" {
+		return text, nil
+	}
+
+	var counts map[string]int
+
+	for _, detector := range secretDetectors {
+		var count int
+
+		if text, count = detector.redact(text); count > 0 {
+			if counts == nil {
+				counts = make(map[string]int)
+			}
+
+			counts[detector.name] += count
+		}
+	}
+
+	return text, counts
+}
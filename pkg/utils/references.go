@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// configReference is a reference from a container to a Secret or ConfigMap, or to a single key of it
+type configReference struct {
+	kind string
+	name string
+	key  string
+}
+
+func (ref configReference) String() string {
+	if ref.key != "" {
+		return fmt.Sprintf("key %q in %s %q", ref.key, ref.kind, ref.name)
+	}
+
+	return fmt.Sprintf("%s %q", ref.kind, ref.name)
+}
+
+// getMissingConfigReferences cross-checks the Secrets and ConfigMaps referenced by the container
+// through envFrom, valueFrom and its mounted volumes against the cluster, and returns the ones
+// which do not exist. References marked as optional are skipped.
+func (f *AgentPodFilter) getMissingConfigReferences(pod *corev1.Pod, containerName string) []configReference {
+	var missing []configReference
+
+	seen := make(map[configReference]bool)
+
+	for _, ref := range getConfigReferences(pod, containerName) {
+		missingRef := f.getMissingReference(pod.Namespace, ref)
+
+		if missingRef != nil && !seen[*missingRef] {
+			seen[*missingRef] = true
+			missing = append(missing, *missingRef)
+		}
+	}
+
+	return missing
+}
+
+// getMissingReference returns the referenced object if it does not exist, the reference itself if
+// only the referenced key does not exist, or nil if the reference is valid
+func (f *AgentPodFilter) getMissingReference(namespace string, ref configReference) *configReference {
+	keys := make(map[string]bool)
+
+	if ref.kind == "Secret" {
+		secret, err := f.kubeClient.CoreV1().Secrets(namespace).Get(context.Background(), ref.name, v1.GetOptions{})
+		if err != nil {
+			// we cannot tell that the secret is missing in case of other errors
+			if errors.IsNotFound(err) {
+				return &configReference{kind: ref.kind, name: ref.name}
+			}
+
+			return nil
+		}
+
+		for key := range secret.Data {
+			keys[key] = true
+		}
+	} else {
+		configMap, err := f.kubeClient.CoreV1().ConfigMaps(namespace).Get(context.Background(), ref.name, v1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return &configReference{kind: ref.kind, name: ref.name}
+			}
+
+			return nil
+		}
+
+		for key := range configMap.Data {
+			keys[key] = true
+		}
+
+		for key := range configMap.BinaryData {
+			keys[key] = true
+		}
+	}
+
+	if ref.key != "" && !keys[ref.key] {
+		return &ref
+	}
+
+	return nil
+}
+
+func getConfigReferences(pod *corev1.Pod, containerName string) []configReference {
+	var refs []configReference
+
+	var container *corev1.Container
+
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			if containers[i].Name == containerName {
+				container = &containers[i]
+			}
+		}
+	}
+
+	if container == nil {
+		return nil
+	}
+
+	for _, envFrom := range container.EnvFrom {
+		if envFrom.SecretRef != nil && !isOptional(envFrom.SecretRef.Optional) {
+			refs = append(refs, configReference{kind: "Secret", name: envFrom.SecretRef.Name})
+		} else if envFrom.ConfigMapRef != nil && !isOptional(envFrom.ConfigMapRef.Optional) {
+			refs = append(refs, configReference{kind: "ConfigMap", name: envFrom.ConfigMapRef.Name})
+		}
+	}
+
+	for _, env := range container.Env {
+		if env.ValueFrom == nil {
+			continue
+		}
+
+		if ref := env.ValueFrom.SecretKeyRef; ref != nil && !isOptional(ref.Optional) {
+			refs = append(refs, configReference{kind: "Secret", name: ref.Name, key: ref.Key})
+		} else if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil && !isOptional(ref.Optional) {
+			refs = append(refs, configReference{kind: "ConfigMap", name: ref.Name, key: ref.Key})
+		}
+	}
+
+	mounted := make(map[string]bool)
+
+	for _, mount := range container.VolumeMounts {
+		mounted[mount.Name] = true
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		if !mounted[volume.Name] {
+			continue
+		}
+
+		if volume.Secret != nil && !isOptional(volume.Secret.Optional) {
+			refs = append(refs, getVolumeReferences("Secret", volume.Secret.SecretName, volume.Secret.Items)...)
+		} else if volume.ConfigMap != nil && !isOptional(volume.ConfigMap.Optional) {
+			refs = append(refs, getVolumeReferences("ConfigMap", volume.ConfigMap.Name, volume.ConfigMap.Items)...)
+		} else if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil && !isOptional(source.Secret.Optional) {
+					refs = append(refs, getVolumeReferences("Secret", source.Secret.Name, source.Secret.Items)...)
+				} else if source.ConfigMap != nil && !isOptional(source.ConfigMap.Optional) {
+					refs = append(refs, getVolumeReferences("ConfigMap", source.ConfigMap.Name, source.ConfigMap.Items)...)
+				}
+			}
+		}
+	}
+
+	return refs
+}
+
+func getVolumeReferences(kind, name string, items []corev1.KeyToPath) []configReference {
+	if len(items) == 0 {
+		return []configReference{{kind: kind, name: name}}
+	}
+
+	var refs []configReference
+
+	for _, item := range items {
+		refs = append(refs, configReference{kind: kind, name: name, key: item.Key})
+	}
+
+	return refs
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReleaseStrategy is a way of identifying the release an object belongs to
+type ReleaseStrategy string
+
+const (
+	// LabelReleaseStrategy uses the value of the release label of the object, which is the
+	// label set by Porter unless configured otherwise
+	LabelReleaseStrategy ReleaseStrategy = "label"
+
+	// HelmReleaseStrategy uses the release annotations which Helm sets on the objects it manages
+	HelmReleaseStrategy ReleaseStrategy = "helm"
+
+	// OwnerReleaseStrategy uses the name of the top-level owner of the object, which covers
+	// workloads applied with kubectl or Kustomize
+	OwnerReleaseStrategy ReleaseStrategy = "owner"
+)
+
+const helmReleaseNameAnnotation = "meta.helm.sh/release-name"
+
+// owners are followed up to this depth, which is enough for cron jobs and deployments
+const maxOwnerDepth = 4
+
+var (
+	releaseStrategies []ReleaseStrategy
+	releaseLabel      string
+)
+
+func init() {
+	viper.SetDefault("RELEASE_STRATEGIES", string(LabelReleaseStrategy))
+	viper.SetDefault("RELEASE_LABEL", "app.kubernetes.io/instance")
+	viper.AutomaticEnv()
+
+	releaseLabel = viper.GetString("RELEASE_LABEL")
+
+	for _, strategy := range strings.Split(viper.GetString("RELEASE_STRATEGIES"), ",") {
+		strategy = strings.TrimSpace(strategy)
+
+		switch ReleaseStrategy(strategy) {
+		case LabelReleaseStrategy, HelmReleaseStrategy, OwnerReleaseStrategy:
+			releaseStrategies = append(releaseStrategies, ReleaseStrategy(strategy))
+		case "":
+		default:
+			panic(fmt.Sprintf("unknown release strategy %q", strategy))
+		}
+	}
+}
+
+// ReleaseResolver identifies the release of an object by trying each of the configured
+// strategies in turn, in the order given by RELEASE_STRATEGIES
+type ReleaseResolver struct {
+	client     client.Client
+	strategies []ReleaseStrategy
+	label      string
+}
+
+func NewReleaseResolver(c client.Client) *ReleaseResolver {
+	return &ReleaseResolver{
+		client:     c,
+		strategies: releaseStrategies,
+		label:      releaseLabel,
+	}
+}
+
+// GetReleaseName returns the name of the release of the object, or an empty string if none of
+// the strategies can identify it
+func (r *ReleaseResolver) GetReleaseName(ctx context.Context, obj client.Object) string {
+	owner := r.GetTopLevelOwner(ctx, obj)
+
+	for _, strategy := range r.strategies {
+		var releaseName string
+
+		switch strategy {
+		case LabelReleaseStrategy:
+			releaseName = r.getLabelReleaseName(obj, owner)
+		case HelmReleaseStrategy:
+			releaseName = getHelmReleaseName(obj, owner)
+		case OwnerReleaseStrategy:
+			releaseName = owner.GetName()
+		}
+
+		if releaseName != "" {
+			return releaseName
+		}
+	}
+
+	return ""
+}
+
+// GetTopLevelOwner follows the controller references of the object up to the workload which
+// is not controlled by anything else, and returns the object itself if it has no controller
+func (r *ReleaseResolver) GetTopLevelOwner(ctx context.Context, obj client.Object) client.Object {
+	current := obj
+
+	for i := 0; i < maxOwnerDepth; i++ {
+		ref := v1.GetControllerOf(current)
+		if ref == nil {
+			break
+		}
+
+		owner := newOwnerObject(ref)
+		if owner == nil {
+			break
+		}
+
+		err := r.client.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: ref.Name}, owner)
+		if err != nil {
+			break
+		}
+
+		current = owner
+	}
+
+	return current
+}
+
+// ListReleasePods returns the pods of the release in the given namespace
+func (r *ReleaseResolver) ListReleasePods(ctx context.Context, namespace, releaseName string) ([]corev1.Pod, error) {
+	pods := &corev1.PodList{}
+
+	opts := []client.ListOption{client.InNamespace(namespace)}
+
+	// only the label strategy can be matched by the API server
+	if len(r.strategies) == 1 && r.strategies[0] == LabelReleaseStrategy {
+		opts = append(opts, client.MatchingLabels{r.label: releaseName})
+	}
+
+	err := r.client.List(ctx, pods, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]corev1.Pod, 0, len(pods.Items))
+
+	for i := range pods.Items {
+		if r.GetReleaseName(ctx, &pods.Items[i]) == releaseName {
+			res = append(res, pods.Items[i])
+		}
+	}
+
+	return res, nil
+}
+
+// GetAutoscalerReleaseName returns the release of the workload scaled by the autoscaler, falling
+// back to the release of the autoscaler itself
+func (r *ReleaseResolver) GetAutoscalerReleaseName(
+	ctx context.Context, hpa *autoscalingv2.HorizontalPodAutoscaler,
+) string {
+	target := newOwnerObject(&v1.OwnerReference{
+		APIVersion: hpa.Spec.ScaleTargetRef.APIVersion,
+		Kind:       hpa.Spec.ScaleTargetRef.Kind,
+	})
+
+	if target != nil {
+		err := r.client.Get(ctx, types.NamespacedName{Namespace: hpa.Namespace, Name: hpa.Spec.ScaleTargetRef.Name}, target)
+		if err == nil {
+			if releaseName := r.GetReleaseName(ctx, target); releaseName != "" {
+				return releaseName
+			}
+		}
+	}
+
+	return r.GetReleaseName(ctx, hpa)
+}
+
+func (r *ReleaseResolver) getLabelReleaseName(obj, owner client.Object) string {
+	if releaseName := obj.GetLabels()[r.label]; releaseName != "" {
+		return releaseName
+	}
+
+	if releaseName := owner.GetLabels()[r.label]; releaseName != "" {
+		return releaseName
+	}
+
+	// workloads which are not labelled themselves may still label their pods
+	return getPodTemplateLabels(owner)[r.label]
+}
+
+// getHelmReleaseName returns the release from the Helm annotations, which are only set on the
+// objects created by Helm and not on the pods and replica sets created from them.
+//
+// The release-namespace annotation is deliberately ignored. Incidents are keyed by the namespace
+// of the objects, which is also the namespace whose incident policies, namespace selection and
+// restart history apply to the pods of the release, so a release installed from another namespace
+// is reported under the namespace it runs in. Qualifying the release name with the namespace
+// instead would put a slash into incident IDs and API paths. Helm refuses to take over objects
+// of another release, so two releases of the same name only run in the same namespace if their
+// objects are named differently, in which case their incidents are combined.
+func getHelmReleaseName(obj, owner client.Object) string {
+	for _, o := range []client.Object{obj, owner} {
+		if releaseName := o.GetAnnotations()[helmReleaseNameAnnotation]; releaseName != "" {
+			return releaseName
+		}
+	}
+
+	return ""
+}
+
+func getPodTemplateLabels(obj client.Object) map[string]string {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return o.Spec.Template.Labels
+	case *appsv1.StatefulSet:
+		return o.Spec.Template.Labels
+	case *appsv1.DaemonSet:
+		return o.Spec.Template.Labels
+	case *appsv1.ReplicaSet:
+		return o.Spec.Template.Labels
+	case *batchv1.Job:
+		return o.Spec.Template.Labels
+	case *batchv1.CronJob:
+		return o.Spec.JobTemplate.Spec.Template.Labels
+	}
+
+	return nil
+}
+
+// newOwnerObject returns an empty object of the kind of the reference, for the kinds of owners
+// which the agent is allowed to read
+func newOwnerObject(ref *v1.OwnerReference) client.Object {
+	switch {
+	case ref.APIVersion == "apps/v1" && ref.Kind == "ReplicaSet":
+		return &appsv1.ReplicaSet{}
+	case ref.APIVersion == "apps/v1" && ref.Kind == "Deployment":
+		return &appsv1.Deployment{}
+	case ref.APIVersion == "apps/v1" && ref.Kind == "StatefulSet":
+		return &appsv1.StatefulSet{}
+	case ref.APIVersion == "apps/v1" && ref.Kind == "DaemonSet":
+		return &appsv1.DaemonSet{}
+	case ref.APIVersion == "batch/v1" && ref.Kind == "Job":
+		return &batchv1.Job{}
+	case ref.APIVersion == "batch/v1" && ref.Kind == "CronJob":
+		return &batchv1.CronJob{}
+	}
+
+	return nil
+}
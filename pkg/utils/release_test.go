@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	testReleaseLabel     = "app.kubernetes.io/instance"
+	testReleaseNamespace = "apps"
+)
+
+func newControllerRef(apiVersion, kind, name string) []v1.OwnerReference {
+	controller := true
+
+	return []v1.OwnerReference{
+		{
+			APIVersion: apiVersion,
+			Kind:       kind,
+			Name:       name,
+			UID:        types.UID(name),
+			Controller: &controller,
+		},
+	}
+}
+
+// newTestReleaseObjects returns a deployment installed by Helm from another namespace, labelling
+// only its pod template, along with its replica set and pod, and a pod of a cron job applied
+// without Helm
+func newTestReleaseObjects() []client.Object {
+	return []client.Object{
+		&appsv1.Deployment{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "web",
+				Namespace: testReleaseNamespace,
+				Annotations: map[string]string{
+					helmReleaseNameAnnotation:        "web-helm",
+					"meta.helm.sh/release-namespace": "porter",
+				},
+			},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: v1.ObjectMeta{Labels: map[string]string{testReleaseLabel: "web-label"}},
+				},
+			},
+		},
+		&appsv1.ReplicaSet{
+			ObjectMeta: v1.ObjectMeta{
+				Name:            "web-5d8f",
+				Namespace:       testReleaseNamespace,
+				OwnerReferences: newControllerRef("apps/v1", "Deployment", "web"),
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{
+				Name:            "web-5d8f-abcde",
+				Namespace:       testReleaseNamespace,
+				OwnerReferences: newControllerRef("apps/v1", "ReplicaSet", "web-5d8f"),
+			},
+		},
+		&batchv1.CronJob{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "report",
+				Namespace: testReleaseNamespace,
+			},
+		},
+		&batchv1.Job{
+			ObjectMeta: v1.ObjectMeta{
+				Name:            "report-27950",
+				Namespace:       testReleaseNamespace,
+				OwnerReferences: newControllerRef("batch/v1", "CronJob", "report"),
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{
+				Name:            "report-27950-xyz12",
+				Namespace:       testReleaseNamespace,
+				OwnerReferences: newControllerRef("batch/v1", "Job", "report-27950"),
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "debug",
+				Namespace: testReleaseNamespace,
+			},
+		},
+	}
+}
+
+func newTestReleaseResolver(strategies ...ReleaseStrategy) *ReleaseResolver {
+	return &ReleaseResolver{
+		client:     fake.NewClientBuilder().WithObjects(newTestReleaseObjects()...).Build(),
+		strategies: strategies,
+		label:      testReleaseLabel,
+	}
+}
+
+func getTestPod(t *testing.T, r *ReleaseResolver, name string) *corev1.Pod {
+	t.Helper()
+
+	pod := &corev1.Pod{}
+
+	if err := r.client.Get(context.Background(), types.NamespacedName{Namespace: testReleaseNamespace, Name: name}, pod); err != nil {
+		t.Fatalf("error fetching pod %s: %v", name, err)
+	}
+
+	return pod
+}
+
+func TestGetReleaseName(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategies []ReleaseStrategy
+		pod        string
+		podLabel   string
+		expected   string
+	}{
+		{
+			name:       "label of pod template",
+			strategies: []ReleaseStrategy{LabelReleaseStrategy},
+			pod:        "web-5d8f-abcde",
+			expected:   "web-label",
+		},
+		{
+			name:       "label of pod",
+			strategies: []ReleaseStrategy{LabelReleaseStrategy},
+			pod:        "web-5d8f-abcde",
+			podLabel:   "web-pod",
+			expected:   "web-pod",
+		},
+		{
+			name:       "missing label",
+			strategies: []ReleaseStrategy{LabelReleaseStrategy},
+			pod:        "report-27950-xyz12",
+		},
+		{
+			// the release namespace annotation of the deployment is ignored
+			name:       "helm",
+			strategies: []ReleaseStrategy{HelmReleaseStrategy},
+			pod:        "web-5d8f-abcde",
+			expected:   "web-helm",
+		},
+		{
+			name:       "missing helm annotations",
+			strategies: []ReleaseStrategy{HelmReleaseStrategy},
+			pod:        "debug",
+		},
+		{
+			name:       "owner of deployment",
+			strategies: []ReleaseStrategy{OwnerReleaseStrategy},
+			pod:        "web-5d8f-abcde",
+			expected:   "web",
+		},
+		{
+			name:       "owner of cron job",
+			strategies: []ReleaseStrategy{OwnerReleaseStrategy},
+			pod:        "report-27950-xyz12",
+			expected:   "report",
+		},
+		{
+			name:       "owner of pod without owner",
+			strategies: []ReleaseStrategy{OwnerReleaseStrategy},
+			pod:        "debug",
+			expected:   "debug",
+		},
+		{
+			name:       "helm before label",
+			strategies: []ReleaseStrategy{HelmReleaseStrategy, LabelReleaseStrategy},
+			pod:        "web-5d8f-abcde",
+			expected:   "web-helm",
+		},
+		{
+			name:       "label before helm",
+			strategies: []ReleaseStrategy{LabelReleaseStrategy, HelmReleaseStrategy},
+			pod:        "web-5d8f-abcde",
+			expected:   "web-label",
+		},
+		{
+			name:       "fall back to owner",
+			strategies: []ReleaseStrategy{LabelReleaseStrategy, HelmReleaseStrategy, OwnerReleaseStrategy},
+			pod:        "report-27950-xyz12",
+			expected:   "report",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestReleaseResolver(test.strategies...)
+			pod := getTestPod(t, r, test.pod)
+
+			if test.podLabel != "" {
+				pod.Labels = map[string]string{testReleaseLabel: test.podLabel}
+			}
+
+			if releaseName := r.GetReleaseName(context.Background(), pod); releaseName != test.expected {
+				t.Errorf("expected release %q, got %q", test.expected, releaseName)
+			}
+		})
+	}
+}
+
+func TestGetAutoscalerReleaseName(t *testing.T) {
+	r := newTestReleaseResolver(LabelReleaseStrategy)
+
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "web",
+			Namespace: testReleaseNamespace,
+			Labels:    map[string]string{testReleaseLabel: "web-hpa"},
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       "web",
+			},
+		},
+	}
+
+	if releaseName := r.GetAutoscalerReleaseName(context.Background(), hpa); releaseName != "web-label" {
+		t.Errorf("expected release of the scaled deployment, got %q", releaseName)
+	}
+
+	hpa.Spec.ScaleTargetRef.Name = "missing"
+
+	if releaseName := r.GetAutoscalerReleaseName(context.Background(), hpa); releaseName != "web-hpa" {
+		t.Errorf("expected release of the autoscaler, got %q", releaseName)
+	}
+}
+
+func TestListReleasePods(t *testing.T) {
+	r := newTestReleaseResolver(OwnerReleaseStrategy)
+
+	pods, err := r.ListReleasePods(context.Background(), testReleaseNamespace, "report")
+	if err != nil {
+		t.Fatalf("error listing release pods: %v", err)
+	}
+
+	var names []string
+
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+
+	if len(names) != 1 || names[0] != "report-27950-xyz12" {
+		t.Errorf("expected only the pod of the cron job, got %v", names)
+	}
+}
@@ -0,0 +1,362 @@
+package utils
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed default_rules.yaml
+var defaultRules []byte
+
+var filterRules []*FilterRule
+
+func init() {
+	viper.SetDefault("FILTER_RULES_FILE", "")
+	viper.AutomaticEnv()
+
+	rules, err := loadFilterRules(viper.GetString("FILTER_RULES_FILE"))
+	if err != nil {
+		panic(fmt.Sprintf("cannot load filter rules: %v", err))
+	}
+
+	filterRules = rules
+}
+
+// FilterRule classifies the failure of a container matched by the rule, see
+// default_rules.yaml for the rules which ship with the agent
+type FilterRule struct {
+	Name     string          `json:"name"`
+	Disabled bool            `json:"disabled,omitempty"`
+	Match    FilterRuleMatch `json:"match"`
+	Events   []string        `json:"events,omitempty"`
+	Summary  string          `json:"summary"`
+	Details  string          `json:"details"`
+	Severity models.Severity `json:"severity,omitempty"`
+
+	pattern   *regexp.Regexp
+	condition *template.Template
+	summary   *template.Template
+	details   *template.Template
+}
+
+type FilterRuleMatch struct {
+	WaitingReasons    []string `json:"waitingReasons,omitempty"`
+	TerminatedReasons []string `json:"terminatedReasons,omitempty"`
+	ExitCodes         []int32  `json:"exitCodes,omitempty"`
+	MessagePattern    string   `json:"messagePattern,omitempty"`
+	Condition         string   `json:"condition,omitempty"`
+}
+
+type filterRuleSet struct {
+	Rules []*FilterRule `json:"rules"`
+}
+
+// ruleData is the data which the templates of a rule are rendered with
+type ruleData struct {
+	ContainerName string
+	Image         string
+	PodName       string
+	Namespace     string
+	PorterHost    string
+
+	// Subject refers to the container at the start of a summary, as "The application" or as
+	// "The init container"
+	Subject string
+
+	WaitingReason    string
+	TerminatedReason string
+	Terminated       bool
+	ExitCode         int32
+
+	// Signal is the name of the signal which killed the container, if any
+	Signal string
+
+	// ExitCodeSummary and ExitCodeDetails explain the exit code of the container
+	ExitCodeSummary string
+	ExitCodeDetails string
+
+	// Message is the message of the termination of the container, or of its waiting state
+	Message string
+
+	// Event is the message of the latest container event for the reasons of the rule
+	Event string
+
+	filter            *AgentPodFilter
+	pod               *corev1.Pod
+	missingReferences []string
+	referencesChecked bool
+}
+
+var ruleFuncs = template.FuncMap{
+	"join":          strings.Join,
+	"filterMessage": getFilteredMessage,
+}
+
+func newRuleData(f *AgentPodFilter, pod *corev1.Pod, status corev1.ContainerStatus, isInit bool) *ruleData {
+	data := &ruleData{
+		ContainerName: status.Name,
+		Subject:       "The " + getContainerKind(isInit),
+		Image:         status.Image,
+		PodName:       pod.Name,
+		Namespace:     pod.Namespace,
+		PorterHost:    porterHost,
+		filter:        f,
+		pod:           pod,
+	}
+
+	if status.State.Waiting != nil && status.State.Waiting.Reason != "" {
+		data.WaitingReason = status.State.Waiting.Reason
+		data.Message = status.State.Waiting.Message
+	}
+
+	if terminated := getContainerTermination(status); terminated != nil {
+		data.Terminated = true
+		data.TerminatedReason = terminated.Reason
+		data.ExitCode = terminated.ExitCode
+		data.Signal = GetExitCodeSignal(terminated.ExitCode)
+		data.ExitCodeSummary, data.ExitCodeDetails = DescribeExitCode(terminated.ExitCode, isInit)
+		data.Message = terminated.Message
+	}
+
+	return data
+}
+
+// MemoryLimit returns the memory limit of the container
+func (d *ruleData) MemoryLimit() string {
+	return getMemoryLimit(d.pod, d.ContainerName)
+}
+
+// MissingReferences returns the Secrets and ConfigMaps referenced by the container which do not
+// exist. They are only looked up by the rules which use them.
+func (d *ruleData) MissingReferences() []string {
+	if !d.referencesChecked {
+		d.referencesChecked = true
+
+		for _, ref := range d.filter.getMissingConfigReferences(d.pod, d.ContainerName) {
+			d.missingReferences = append(d.missingReferences, ref.String())
+		}
+	}
+
+	return d.missingReferences
+}
+
+// applyFilterRules returns the summary, details and severity of the first rule which matches the
+// container status, or empty strings if no rule matches
+func (f *AgentPodFilter) applyFilterRules(
+	pod *corev1.Pod, status corev1.ContainerStatus, fieldPath string, isInit bool,
+) (string, string, models.Severity) {
+	data := newRuleData(f, pod, status, isInit)
+
+	for _, rule := range filterRules {
+		if !rule.matches(data) {
+			continue
+		}
+
+		data.Event = ""
+
+		if len(rule.Events) > 0 {
+			if event := f.getContainerEventForReasons(pod.Name, pod.Namespace, fieldPath, rule.Events...); event != nil {
+				data.Event = event.Message
+			}
+		}
+
+		if rule.condition != nil {
+			if condition, err := render(rule.condition, data); err != nil || strings.TrimSpace(condition) != "true" {
+				continue
+			}
+		}
+
+		summary, err := render(rule.summary, data)
+		if err != nil {
+			return "", "", ""
+		}
+
+		details, err := render(rule.details, data)
+		if err != nil {
+			return "", "", ""
+		}
+
+		severity := rule.Severity
+
+		if severity == "" {
+			severity = models.SeverityMedium
+		}
+
+		return summary, details, severity
+	}
+
+	return "", "", ""
+}
+
+func (rule *FilterRule) matches(data *ruleData) bool {
+	if len(rule.Match.WaitingReasons) > 0 && !containsString(rule.Match.WaitingReasons, data.WaitingReason) {
+		return false
+	}
+
+	if len(rule.Match.TerminatedReasons) > 0 &&
+		(!data.Terminated || !containsString(rule.Match.TerminatedReasons, data.TerminatedReason)) {
+		return false
+	}
+
+	if len(rule.Match.ExitCodes) > 0 {
+		if !data.Terminated {
+			return false
+		}
+
+		found := false
+
+		for _, exitCode := range rule.Match.ExitCodes {
+			if exitCode == data.ExitCode {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if rule.pattern != nil && !rule.pattern.MatchString(data.Message) {
+		return false
+	}
+
+	return true
+}
+
+// compile parses the pattern and the templates of the rule
+func (rule *FilterRule) compile() error {
+	var err error
+
+	if rule.Name == "" {
+		return fmt.Errorf("rule without a name")
+	}
+
+	if _, ok := models.ParseSeverity(string(rule.Severity)); rule.Severity != "" && !ok {
+		return fmt.Errorf("invalid severity %q of rule %s", rule.Severity, rule.Name)
+	}
+
+	if rule.Match.MessagePattern != "" {
+		if rule.pattern, err = regexp.Compile(rule.Match.MessagePattern); err != nil {
+			return fmt.Errorf("invalid message pattern of rule %s: %w", rule.Name, err)
+		}
+	}
+
+	if rule.Match.Condition != "" {
+		if rule.condition, err = template.New("condition").Funcs(ruleFuncs).Parse(rule.Match.Condition); err != nil {
+			return fmt.Errorf("invalid condition of rule %s: %w", rule.Name, err)
+		}
+	}
+
+	if rule.summary, err = template.New("summary").Funcs(ruleFuncs).Parse(rule.Summary); err != nil {
+		return fmt.Errorf("invalid summary of rule %s: %w", rule.Name, err)
+	}
+
+	if rule.details, err = template.New("details").Funcs(ruleFuncs).Parse(rule.Details); err != nil {
+		return fmt.Errorf("invalid details of rule %s: %w", rule.Name, err)
+	}
+
+	return nil
+}
+
+// loadFilterRules returns the default rules merged with the rules of the given file, if any.
+// A rule of the file replaces the default rule with the same name, or is evaluated before the
+// default rules if there is none. Disabled rules are dropped.
+func loadFilterRules(path string) ([]*FilterRule, error) {
+	rules, err := parseFilterRules(defaultRules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default rules: %w", err)
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		userRules, err := parseFilterRules(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rules in %s: %w", path, err)
+		}
+
+		var added []*FilterRule
+
+		for _, userRule := range userRules {
+			replaced := false
+
+			for i, rule := range rules {
+				if rule.Name == userRule.Name {
+					rules[i] = userRule
+					replaced = true
+					break
+				}
+			}
+
+			if !replaced {
+				added = append(added, userRule)
+			}
+		}
+
+		rules = append(added, rules...)
+	}
+
+	res := make([]*FilterRule, 0, len(rules))
+
+	for _, rule := range rules {
+		if !rule.Disabled {
+			res = append(res, rule)
+		}
+	}
+
+	return res, nil
+}
+
+func parseFilterRules(data []byte) ([]*FilterRule, error) {
+	ruleSet := &filterRuleSet{}
+
+	if err := yaml.UnmarshalStrict(data, ruleSet); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range ruleSet.Rules {
+		if rule.Disabled {
+			continue
+		}
+
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+	}
+
+	return ruleSet.Rules, nil
+}
+
+func render(tmpl *template.Template, data *ruleData) (string, error) {
+	var buf bytes.Buffer
+
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,427 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testPorterHost = "https://dashboard.example.com"
+
+// newTestPodFilter returns a filter whose client lists the given events. The fake clientset
+// ignores field selectors, so they are applied to the events here.
+func newTestPodFilter(events ...corev1.Event) *AgentPodFilter {
+	kubeClient := fake.NewSimpleClientset()
+
+	kubeClient.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		selector := action.(k8stesting.ListAction).GetListRestrictions().Fields
+		res := &corev1.EventList{}
+
+		for _, event := range events {
+			if selector.Matches(fields.Set{
+				"involvedObject.kind":      event.InvolvedObject.Kind,
+				"involvedObject.name":      event.InvolvedObject.Name,
+				"involvedObject.fieldPath": event.InvolvedObject.FieldPath,
+				"reason":                   event.Reason,
+			}) {
+				res.Items = append(res.Items, event)
+			}
+		}
+
+		return true, res, nil
+	})
+
+	return &AgentPodFilter{kubeClient: kubeClient}
+}
+
+func newTestPod(phase corev1.PodPhase, statuses ...corev1.ContainerStatus) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "web-7d4b9c-x2x9z",
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "web",
+					Image: "registry.example.com/web:1.0.0",
+					Resources: corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+					},
+				},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase:             phase,
+			ContainerStatuses: statuses,
+		},
+	}
+}
+
+func newCrashLoopStatus(terminated corev1.ContainerStateTerminated) corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Name:  "web",
+		Image: "registry.example.com/web:1.0.0",
+		State: corev1.ContainerState{
+			Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+		},
+		LastTerminationState: corev1.ContainerState{Terminated: &terminated},
+	}
+}
+
+func newWaitingStatus(reason string) corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Name:  "web",
+		Image: "registry.example.com/web:1.0.0",
+		State: corev1.ContainerState{
+			Waiting: &corev1.ContainerStateWaiting{Reason: reason},
+		},
+	}
+}
+
+func setTestPorterHost(t *testing.T) {
+	defaultPorterHost := porterHost
+	porterHost = testPorterHost
+
+	t.Cleanup(func() {
+		porterHost = defaultPorterHost
+	})
+}
+
+// TestFilterDefaultRules checks that the default rules classify pods like the classifier which
+// they replaced did
+func TestFilterDefaultRules(t *testing.T) {
+	setTestPorterHost(t)
+
+	probeEvent := corev1.Event{
+		InvolvedObject: corev1.ObjectReference{
+			Kind:      "Pod",
+			Name:      "web-7d4b9c-x2x9z",
+			FieldPath: "spec.containers{web}",
+		},
+		Reason:  "Killing",
+		Message: "Container web failed liveness probe, will be restarted",
+	}
+
+	tests := []struct {
+		name            string
+		pod             *corev1.Pod
+		events          []corev1.Event
+		expectedSummary string
+		expectedDetails string
+	}{
+		{
+			name: "out of memory",
+			pod: newTestPod(corev1.PodRunning, newCrashLoopStatus(corev1.ContainerStateTerminated{
+				Reason:   "OOMKilled",
+				ExitCode: 137,
+			})),
+			expectedSummary: "The application was killed because it used too much memory",
+			expectedDetails: "The application exceeded its memory limit of 512Mi. Reduce the amount of memory " +
+				"your application is using or increase the memory limit - see the docs here for more information: " +
+				"https://docs.porter.run/managing-applications/application-troubleshooting#memory-usage",
+		},
+		{
+			name:            "image pull back off",
+			pod:             newTestPod(corev1.PodPending, newWaitingStatus("ImagePullBackOff")),
+			expectedSummary: "The image could not be pulled from the registry",
+			expectedDetails: "The application was unable to pull image registry.example.com/web:1.0.0. Please make " +
+				"sure you have linked this image registry to Porter by navigating to " + testPorterHost +
+				"/integrations/registry. See documentation for linking your registry here: " +
+				"https://docs.porter.run/deploying-applications/deploying-from-docker-registry/linking-existing-registry",
+		},
+		{
+			name:            "invalid image name",
+			pod:             newTestPod(corev1.PodPending, newWaitingStatus("InvalidImageName")),
+			expectedSummary: "The image could not be pulled from the registry because the image URI is invalid",
+			expectedDetails: "The specified image registry.example.com/web:1.0.0 is not a valid image URI.",
+		},
+		{
+			name: "crash loop back off",
+			pod: newTestPod(corev1.PodRunning, newCrashLoopStatus(corev1.ContainerStateTerminated{
+				Reason:   "Error",
+				ExitCode: 3,
+			})),
+			expectedSummary: "The application exited with exit code 3",
+			expectedDetails: "The application exited with exit code 3. We recommend looking into " +
+				"https://docs.porter.run/managing-applications/alerting/pod-exit-codes to further debug the reason " +
+				"for the crash.",
+		},
+		{
+			name: "crash loop back off with a known exit code",
+			pod: newTestPod(corev1.PodRunning, newCrashLoopStatus(corev1.ContainerStateTerminated{
+				Reason:   "Error",
+				ExitCode: 1,
+			})),
+			expectedSummary: "The application exited with a general error (exit code 1)",
+			expectedDetails: knownExitCodes[1].details + " " + exitCodeDocs,
+		},
+		{
+			name: "crash loop back off killed by probe",
+			pod: newTestPod(corev1.PodRunning, newCrashLoopStatus(corev1.ContainerStateTerminated{
+				Reason:   "Error",
+				ExitCode: 137,
+			})),
+			events:          []corev1.Event{probeEvent},
+			expectedSummary: "The application was killed by SIGKILL (exit code 137)",
+			expectedDetails: probeEvent.Message,
+		},
+		{
+			name: "crash loop back off without a reason",
+			pod: newTestPod(corev1.PodRunning, newCrashLoopStatus(corev1.ContainerStateTerminated{
+				ExitCode: 1,
+			})),
+		},
+		{
+			name: "crash loop back off cannot run",
+			pod: newTestPod(corev1.PodRunning, newCrashLoopStatus(corev1.ContainerStateTerminated{
+				Reason:   "StartError",
+				ExitCode: 128,
+				Message: "failed to create containerd task: OCI runtime create failed: starting container " +
+					"process caused: exec: \"./server\": permission denied",
+			})),
+			expectedSummary: "The application could not start running",
+			expectedDetails: "exec: \"./server\": permission denied",
+		},
+		{
+			name: "terminated without a reason",
+			pod: newTestPod(corev1.PodFailed, corev1.ContainerStatus{
+				Name: "web",
+				State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{ExitCode: 3},
+				},
+			}),
+			expectedSummary: "The application exited with exit code 3",
+			expectedDetails: "The application exited with exit code 3. We recommend looking into " +
+				"https://docs.porter.run/managing-applications/alerting/pod-exit-codes to further debug the reason " +
+				"for the crash.",
+		},
+		{
+			name: "completed",
+			pod: newTestPod(corev1.PodSucceeded, corev1.ContainerStatus{
+				Name: "web",
+				State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{Reason: "Completed"},
+				},
+			}),
+		},
+		{
+			name: "pending",
+			pod:  newTestPod(corev1.PodPending, newWaitingStatus("ContainerCreating")),
+		},
+		{
+			name: "running",
+			pod: newTestPod(corev1.PodRunning, corev1.ContainerStatus{
+				Name: "web",
+				State: corev1.ContainerState{
+					Running: &corev1.ContainerStateRunning{},
+				},
+			}),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := newTestPodFilter(test.events...).Filter(test.pod, false)
+
+			if test.expectedSummary == "" {
+				if res != nil {
+					t.Fatalf("expected pod to be ignored, got summary %q", res.PodSummary)
+				}
+
+				return
+			}
+
+			if res == nil {
+				t.Fatalf("expected summary %q, got none", test.expectedSummary)
+			}
+
+			if res.PodSummary != test.expectedSummary {
+				t.Errorf("expected summary %q, got %q", test.expectedSummary, res.PodSummary)
+			}
+
+			if res.PodDetails != test.expectedDetails {
+				t.Errorf("expected details %q, got %q", test.expectedDetails, res.PodDetails)
+			}
+		})
+	}
+}
+
+func TestFilterUnschedulablePod(t *testing.T) {
+	pod := newTestPod(corev1.PodPending)
+	pod.Status.Conditions = []corev1.PodCondition{
+		{
+			Type:               corev1.PodScheduled,
+			Status:             corev1.ConditionFalse,
+			Reason:             corev1.PodReasonUnschedulable,
+			Message:            "0/3 nodes are available: 3 Insufficient memory.",
+			LastTransitionTime: v1.NewTime(time.Now()),
+		},
+	}
+
+	filter := newTestPodFilter()
+
+	if res := filter.Filter(pod, false); res != nil {
+		t.Fatalf("expected pod to be ignored during its grace period, got summary %q", res.PodSummary)
+	}
+
+	pod.Status.Conditions[0].LastTransitionTime = v1.NewTime(time.Now().Add(-unschedulableGracePeriod - time.Minute))
+
+	res := filter.Filter(pod, false)
+	if res == nil {
+		t.Fatal("expected unschedulable pod to be reported after its grace period")
+	}
+
+	if expected := "The application cannot be scheduled: 0/3 nodes are available: 3 Insufficient memory."; res.PodSummary != expected {
+		t.Errorf("expected summary %q, got %q", expected, res.PodSummary)
+	}
+}
+
+func TestFilterRulesFile(t *testing.T) {
+	setTestPorterHost(t)
+
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+
+	err := os.WriteFile(path, []byte(`rules:
+- name: image-pull
+  match:
+    waitingReasons: ["ErrImagePull", "ImagePullBackOff"]
+  severity: low
+  summary: "The image {{ .Image }} could not be pulled"
+  details: "Check the credentials of the registry."
+
+- name: out-of-memory
+  disabled: true
+
+- name: segfault
+  match:
+    exitCodes: [139]
+  severity: critical
+  summary: "{{ .Subject }} crashed"
+  details: "The application crashed with {{ .Signal }}."
+`), 0o600)
+	if err != nil {
+		t.Fatalf("error writing rules file: %v", err)
+	}
+
+	rules, err := loadFilterRules(path)
+	if err != nil {
+		t.Fatalf("error loading rules file: %v", err)
+	}
+
+	defaultFilterRules := filterRules
+	filterRules = rules
+
+	t.Cleanup(func() {
+		filterRules = defaultFilterRules
+	})
+
+	if rules[0].Name != "segfault" {
+		t.Errorf("expected added rule to be evaluated first, got %s", rules[0].Name)
+	}
+
+	for _, rule := range rules {
+		if rule.Name == "out-of-memory" {
+			t.Errorf("expected disabled rule to be dropped")
+		}
+	}
+
+	tests := []struct {
+		name             string
+		pod              *corev1.Pod
+		expectedSummary  string
+		expectedSeverity string
+	}{
+		{
+			name:             "replaced rule",
+			pod:              newTestPod(corev1.PodPending, newWaitingStatus("ImagePullBackOff")),
+			expectedSummary:  "The image registry.example.com/web:1.0.0 could not be pulled",
+			expectedSeverity: "low",
+		},
+		{
+			name: "added rule",
+			pod: newTestPod(corev1.PodRunning, newCrashLoopStatus(corev1.ContainerStateTerminated{
+				Reason:   "Error",
+				ExitCode: 139,
+			})),
+			expectedSummary:  "The application crashed",
+			expectedSeverity: "critical",
+		},
+		{
+			name: "default rule",
+			pod: newTestPod(corev1.PodRunning, newCrashLoopStatus(corev1.ContainerStateTerminated{
+				Reason:   "Error",
+				ExitCode: 3,
+			})),
+			expectedSummary:  "The application exited with exit code 3",
+			expectedSeverity: "high",
+		},
+		{
+			name: "disabled rule",
+			pod: newTestPod(corev1.PodRunning, newCrashLoopStatus(corev1.ContainerStateTerminated{
+				Reason:   "OOMKilled",
+				ExitCode: 137,
+			})),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := newTestPodFilter().Filter(test.pod, false)
+
+			if test.expectedSummary == "" {
+				if res != nil {
+					t.Fatalf("expected pod to be ignored, got summary %q", res.PodSummary)
+				}
+
+				return
+			}
+
+			if res == nil {
+				t.Fatalf("expected summary %q, got none", test.expectedSummary)
+			}
+
+			if res.PodSummary != test.expectedSummary {
+				t.Errorf("expected summary %q, got %q", test.expectedSummary, res.PodSummary)
+			}
+
+			if string(res.Severity) != test.expectedSeverity {
+				t.Errorf("expected severity %s, got %s", test.expectedSeverity, res.Severity)
+			}
+		})
+	}
+}
+
+func TestLoadFilterRulesInvalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":    "rules:\n- name: typo\n  summary: x\n  detials: y\n",
+		"missing name":     "rules:\n- summary: x\n  details: y\n",
+		"invalid pattern":  "rules:\n- name: bad\n  match:\n    messagePattern: \"(\"\n  summary: x\n  details: y\n",
+		"invalid template": "rules:\n- name: bad\n  summary: \"{{ .Image \"\n  details: y\n",
+		"invalid severity": "rules:\n- name: bad\n  severity: urgent\n  summary: x\n  details: y\n",
+	}
+
+	for name, rules := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.yaml")
+
+			if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+				t.Fatalf("error writing rules file: %v", err)
+			}
+
+			if _, err := loadFilterRules(path); err == nil || !strings.Contains(err.Error(), path) {
+				t.Errorf("expected an error about %s, got %v", path, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/porter-dev/porter-agent/pkg/models"
+	"github.com/spf13/viper"
+)
+
+// ongoing incidents are escalated by at most this many levels, so that an incident which starts
+// out as low, such as a failed image pull on a preview branch, never reaches the severity of an
+// incident which starts out as high
+const maxSeverityEscalation = 1
+
+var severityEscalationPeriod time.Duration
+
+func init() {
+	viper.SetDefault("SEVERITY_ESCALATION_PERIOD", "0")
+	viper.AutomaticEnv()
+
+	severityEscalationPeriod = viper.GetDuration("SEVERITY_ESCALATION_PERIOD")
+}
+
+// GetEventSeverity returns the severity of an event. Events stored before severities were
+// introduced have none, and are treated as medium.
+func GetEventSeverity(event *models.PodEvent) models.Severity {
+	if event.Severity == "" {
+		return models.SeverityMedium
+	}
+
+	return event.Severity
+}
+
+// GetEventsSeverity returns the highest severity of the given events of an incident, along with
+// the severity set by the incident policy of the release on the latest event, if any. The stores
+// keep both as events are added, so this is only needed for incidents stored before they did.
+func GetEventsSeverity(events []*models.PodEvent) (models.Severity, models.Severity) {
+	var (
+		severity    models.Severity
+		latestEvent *models.PodEvent
+	)
+
+	for _, event := range events {
+		if latestEvent == nil || event.Timestamp > latestEvent.Timestamp {
+			latestEvent = event
+		}
+
+		severity = models.MaxSeverity(severity, GetEventSeverity(event))
+	}
+
+	if latestEvent == nil {
+		return "", ""
+	}
+
+	return severity, latestEvent.PolicySeverity
+}
+
+// GetIncidentSeverity returns the severity of an incident from the highest severity of its events,
+// raised by one level once the incident has been ongoing for the escalation period until the given
+// time. Escalation is turned off with an escalation period of 0, which is the default. The severity
+// set by the incident policy of the release on the latest event takes precedence, and does not
+// escalate.
+func GetIncidentSeverity(severity, policySeverity models.Severity, createdAt, until time.Time) models.Severity {
+	if policySeverity != "" {
+		return policySeverity
+	}
+
+	if severity == "" {
+		severity = models.SeverityMedium
+	}
+
+	if severityEscalationPeriod > 0 && until.After(createdAt) {
+		levels := int(until.Sub(createdAt) / severityEscalationPeriod)
+
+		if levels > maxSeverityEscalation {
+			levels = maxSeverityEscalation
+		}
+
+		severity = severity.Escalate(levels)
+	}
+
+	return severity
+}
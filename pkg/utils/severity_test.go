@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/porter-dev/porter-agent/pkg/models"
+)
+
+func TestGetIncidentSeverity(t *testing.T) {
+	defaultEscalationPeriod := severityEscalationPeriod
+
+	t.Cleanup(func() {
+		severityEscalationPeriod = defaultEscalationPeriod
+	})
+
+	createdAt := time.Unix(1672531200, 0)
+
+	tests := []struct {
+		name             string
+		escalationPeriod time.Duration
+		events           []*models.PodEvent
+		ongoingFor       time.Duration
+		expected         models.Severity
+	}{
+		{
+			name: "highest event severity",
+			events: []*models.PodEvent{
+				{Timestamp: 1, Severity: models.SeverityLow},
+				{Timestamp: 2, Severity: models.SeverityHigh},
+				{Timestamp: 3, Severity: models.SeverityMedium},
+			},
+			ongoingFor: 24 * time.Hour,
+			expected:   models.SeverityHigh,
+		},
+		{
+			name:       "events without severity",
+			events:     []*models.PodEvent{{Timestamp: 1}},
+			ongoingFor: time.Minute,
+			expected:   models.SeverityMedium,
+		},
+		{
+			name:             "within escalation period",
+			escalationPeriod: time.Hour,
+			events:           []*models.PodEvent{{Timestamp: 1, Severity: models.SeverityLow}},
+			ongoingFor:       59 * time.Minute,
+			expected:         models.SeverityLow,
+		},
+		{
+			name:             "escalated",
+			escalationPeriod: time.Hour,
+			events:           []*models.PodEvent{{Timestamp: 1, Severity: models.SeverityLow}},
+			ongoingFor:       time.Hour,
+			expected:         models.SeverityMedium,
+		},
+		{
+			name:             "escalated by at most one level",
+			escalationPeriod: time.Hour,
+			events:           []*models.PodEvent{{Timestamp: 1, Severity: models.SeverityLow}},
+			ongoingFor:       72 * time.Hour,
+			expected:         models.SeverityMedium,
+		},
+		{
+			name:             "policy severity",
+			escalationPeriod: time.Hour,
+			events: []*models.PodEvent{
+				{Timestamp: 1, Severity: models.SeverityHigh},
+				{Timestamp: 2, Severity: models.SeverityLow, PolicySeverity: models.SeverityLow},
+			},
+			ongoingFor: 72 * time.Hour,
+			expected:   models.SeverityLow,
+		},
+		{
+			name:             "policy severity of earlier event",
+			escalationPeriod: time.Hour,
+			events: []*models.PodEvent{
+				{Timestamp: 1, Severity: models.SeverityLow, PolicySeverity: models.SeverityLow},
+				{Timestamp: 2, Severity: models.SeverityHigh},
+			},
+			ongoingFor: 30 * time.Minute,
+			expected:   models.SeverityHigh,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			severityEscalationPeriod = test.escalationPeriod
+
+			eventsSeverity, policySeverity := GetEventsSeverity(test.events)
+
+			severity := GetIncidentSeverity(eventsSeverity, policySeverity, createdAt, createdAt.Add(test.ongoingFor))
+
+			if severity != test.expected {
+				t.Errorf("expected severity %s, got %s", test.expected, severity)
+			}
+		})
+	}
+}